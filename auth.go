@@ -0,0 +1,285 @@
+package gomiabdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// loginResponse is the body returned by the box's /admin/login endpoint.
+type loginResponse struct {
+	apiStatus
+	Email      string   `json:"email"`
+	Privileges []string `json:"privileges"`
+	ApiKey     string   `json:"api_key"`
+}
+
+// Login authenticates against the box with the client's email and password and caches the api
+// key it returns. Later requests authenticate with that key instead of the password. When the box
+// rejects the key, for ex. because it restarted or the key expired, the client logs in again and
+// resends the request once; callers don't need to handle it. Calling Login is optional, requests
+// authenticate with the password until it has been called, but it lets a long running service
+// find out about bad credentials at startup.
+func (c *Client) Login(ctx context.Context) error {
+	if !c.hasPassword() {
+		return fmt.Errorf("Login requires a password, this client was created with NewWithAPIKey")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.login(ctx)
+}
+
+// Ping checks that the box can be reached and the client's credentials are accepted, by logging in
+// unless the client already has an api key and then listing the zones. It changes nothing on the
+// box, which makes it suitable for readiness probes.
+func (c *Client) Ping(ctx context.Context) error {
+	if c.APIKey() == "" {
+		if err := c.Login(ctx); err != nil {
+			return err
+		}
+	}
+	_, err := c.GetZones(ctx)
+	return err
+}
+
+// totpSkew are the offsets from the current time codes are generated for, in order, when the
+// box rejects a login. Trying the neighboring 30 second windows copes with a clock that is off.
+var totpSkew = []time.Duration{0, -totpPeriod, totpPeriod}
+
+// login does the work of Login. c.mu must be held.
+func (c *Client) login(ctx context.Context) error {
+	var loginErr error
+	for _, skew := range totpSkew {
+		code, err := c.totpCode(skew)
+		if err != nil {
+			return err
+		}
+		login, err := c.postLogin(ctx, code)
+		if err != nil {
+			return err
+		}
+		if login.Status == "ok" {
+			if !slices.Contains(login.Privileges, "admin") {
+				return fmt.Errorf("Login failed for %s: %w", login.Email, ErrNotAdmin)
+			}
+			c.apikey = login.ApiKey
+			return nil
+		}
+		if loginErr == nil && login.Status == "missing-totp-token" {
+			loginErr = fmt.Errorf("Login failed (%s): %w", login.Status, ErrTOTPRequired)
+		} else if loginErr == nil && strings.Contains(login.Reason, "invalid-totp-token") {
+			loginErr = fmt.Errorf("Login failed (%s): %w", login.Status, ErrTOTPRejected)
+		} else if loginErr == nil {
+			loginErr = fmt.Errorf("Login failed (%s): %s", login.Status, login.Reason)
+		}
+		if c.TOTPCode != "" || c.TOTPSecret == "" || login.Status != "invalid" {
+			break
+		}
+	}
+	return loginErr
+}
+
+// totpCode returns the code a login sends: the client's TOTPCode, or if it has none and has a
+// TOTPSecret, a code for the current time plus skew.
+func (c *Client) totpCode(skew time.Duration) (string, error) {
+	if c.TOTPCode != "" || c.TOTPSecret == "" {
+		return c.TOTPCode, nil
+	}
+	return generateTOTP(c.TOTPSecret, c.clock().Add(skew))
+}
+
+// postLogin sends a login request with code as the two-factor authentication token, or without
+// one if code is empty.
+func (c *Client) postLogin(ctx context.Context, code string) (loginResponse, error) {
+	password, _ := c.ApiUrl.User.Password()
+	req := apiRequest{secret: password, method: http.MethodPost, url: c.adminUrl("login").String(), authToken: code}
+	body, err := c.send(ctx, req)
+	if err != nil {
+		return loginResponse{}, err
+	}
+	var login loginResponse
+	if err := unmarshalJSON(body, &login); err != nil {
+		return loginResponse{}, err
+	}
+	return login, nil
+}
+
+// VerifyTOTP checks that the client's TOTPSecret, or TOTPCode, gives codes the box accepts, for ex.
+// before storing a newly configured secret. It logs in without a code first, to tell two-factor
+// authentication problems from others: it returns the login error when the email or password is
+// wrong, an error when the account doesn't have two-factor authentication enabled so the box
+// doesn't check codes, ErrTOTPRequired when the client has no secret and ErrTOTPRejected when the
+// box rejects the code. On success the api key is cached as by Login.
+func (c *Client) VerifyTOTP(ctx context.Context) error {
+	if !c.hasPassword() {
+		return fmt.Errorf("VerifyTOTP requires a password, this client was created with NewWithAPIKey")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	login, err := c.postLogin(ctx, "")
+	if err != nil {
+		return err
+	}
+	switch login.Status {
+	case "ok":
+		return fmt.Errorf("Two-factor authentication is not enabled for %s, the box doesn't check codes", c.ApiUrl.User.Username())
+	case "missing-totp-token":
+	default:
+		return fmt.Errorf("Login failed (%s): %s", login.Status, login.Reason)
+	}
+	if c.TOTPSecret == "" && c.TOTPCode == "" {
+		return fmt.Errorf("Login failed (%s): %w", login.Status, ErrTOTPRequired)
+	}
+	return c.login(ctx)
+}
+
+// Logout ends the session of the api key cached by Login on the box, through its /admin/logout
+// endpoint, so the key can't be used anymore. The client forgets the key even if that fails, and
+// later requests authenticate with the password again, logging in first if two-factor
+// authentication requires it. A client created with NewWithAPIKey has nothing to fall back on.
+func (c *Client) Logout(ctx context.Context) error {
+	c.mu.Lock()
+	apikey := c.apikey
+	c.apikey = ""
+	c.mu.Unlock()
+	if apikey == "" {
+		return nil
+	}
+	_, err := c.send(ctx, apiRequest{secret: apikey, method: http.MethodPost, url: c.adminUrl("logout").String()})
+	return err
+}
+
+// APIKey returns the api key cached by Login, or an empty string if the client hasn't logged in.
+func (c *Client) APIKey() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.apikey
+}
+
+// apiKeyContextKey is the key of the api key ContextWithAPIKey stores in a context.
+type apiKeyContextKey struct{}
+
+// ContextWithAPIKey returns a copy of ctx carrying apiKey, so that the key of a client that logged
+// in once, as returned by its APIKey, can be shared with code creating short lived clients with
+// NewWithAPIKey and APIKeyFromContext, for ex. by an http middleware for its handlers. The key is
+// an immutable string, safe to use from any number of goroutines and clients at once, but it is a
+// single session on the box: once any client sharing it calls Logout, or the box expires it, all
+// of them get errors wrapping ErrAPIKeyRejected.
+func ContextWithAPIKey(ctx context.Context, apiKey string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, apiKey)
+}
+
+// APIKeyFromContext returns the api key stored in ctx by ContextWithAPIKey, and whether there is one.
+func APIKeyFromContext(ctx context.Context) (string, bool) {
+	apiKey, ok := ctx.Value(apiKeyContextKey{}).(string)
+	return apiKey, ok && apiKey != ""
+}
+
+// requestSecret returns the secret a request authenticates with. Clients with a TOTPSecret or
+// TOTPCode log in first if they haven't yet, since the box requires a new code for every request
+// authenticated with the password.
+func (c *Client) requestSecret(ctx context.Context) (string, error) {
+	if c.TOTPSecret != "" || c.TOTPCode != "" {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.apikey == "" {
+			if err := c.login(ctx); err != nil {
+				return "", err
+			}
+		}
+		return c.apikey, nil
+	}
+	return c.secret(), nil
+}
+
+// withAuthentication sets the secret of req to the one requestSecret returns and calls send with
+// it. The box forgets its api keys when it restarts, and expires them, so when it rejects the
+// cached key of a client that has a password, the key is dropped, the client logs in again and
+// req is sent once more. A second rejection is returned as is.
+func (c *Client) withAuthentication(ctx context.Context, req apiRequest, send func(apiRequest) error) error {
+	for retried := false; ; retried = true {
+		secret, err := c.requestSecret(ctx)
+		if err != nil {
+			return err
+		}
+		req.secret = secret
+		err = send(req)
+		if retried || !c.isRejectedAPIKey(err, secret) {
+			return err
+		}
+		if err := c.relogin(ctx, secret); err != nil {
+			return err
+		}
+	}
+}
+
+// isRejectedAPIKey reports whether err is the box refusing secret, a cached api key the client
+// can replace by logging in again. A TOTPCode can't be used for that, the box accepts it once.
+func (c *Client) isRejectedAPIKey(err error, secret string) bool {
+	password, ok := c.ApiUrl.User.Password()
+	if !ok || secret == password || (c.TOTPCode != "" && c.TOTPSecret == "") {
+		return false
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// relogin drops the rejected api key and logs in again, unless another request already did.
+func (c *Client) relogin(ctx context.Context, rejected string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.apikey != rejected {
+		return nil
+	}
+	c.apikey = ""
+	return c.login(ctx)
+}
+
+// secret returns what requests use as their basic auth password: the cached api key, or the
+// password when there is none.
+func (c *Client) secret() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.apikey != "" {
+		return c.apikey
+	}
+	password, _ := c.ApiUrl.User.Password()
+	return password
+}
+
+// ensureLoggedIn logs in unless the client already has an api key or has no password to log in with.
+func (c *Client) ensureLoggedIn(ctx context.Context) error {
+	if !c.hasPassword() {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.apikey != "" {
+		return nil
+	}
+	return c.login(ctx)
+}
+
+// hasPassword reports whether the client was created with a password rather than an api key.
+func (c *Client) hasPassword() bool {
+	_, ok := c.ApiUrl.User.Password()
+	return ok
+}
+
+// adminUrl returns the url of elem under the box's /admin api: AdminUrl, or the one the custom dns
+// endpoint in ApiUrl lives under.
+func (c *Client) adminUrl(elem ...string) *url.URL {
+	if c.AdminUrl != nil {
+		return c.AdminUrl.JoinPath(elem...)
+	}
+	base := *c.ApiUrl
+	base.Path = strings.TrimSuffix(strings.TrimSuffix(base.Path, "/"), customDNSPath)
+	base.RawPath = ""
+	return base.JoinPath(elem...)
+}
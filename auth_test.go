@@ -0,0 +1,159 @@
+package gomiabdns_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/luv2code/gomiabdns"
+	"github.com/luv2code/gomiabdns/miabtest"
+)
+
+// keyServer is a box that issues a new api key for every login and accepts only the keys in
+// valid, or none with rejectAll.
+type keyServer struct {
+	mu        sync.Mutex
+	logins    int
+	valid     map[string]bool
+	rejectAll bool
+}
+
+func (s *keyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, secret, _ := r.BasicAuth()
+	if r.URL.Path == "/admin/login" {
+		s.logins++
+		key := fmt.Sprintf("key-%d", s.logins)
+		s.valid[key] = true
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": "ok", "email": "admin@example.com", "privileges": []string{"admin"}, "api_key": key})
+		return
+	}
+	if !s.valid[secret] || s.rejectAll {
+		http.Error(w, `{"status": "invalid", "reason": "Invalid API key."}`, http.StatusForbidden)
+		return
+	}
+	_, _ = w.Write([]byte("[]"))
+}
+
+func TestReloginWhenAPIKeyRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		// expire makes the box forget every key after Login.
+		expire bool
+		// persistent makes the box reject the keys of later logins too.
+		persistent bool
+		wantErr    bool
+		wantLogins int
+	}{
+		{name: "valid key", wantLogins: 1},
+		{name: "expired key", expire: true, wantLogins: 2},
+		{name: "persistent rejection", expire: true, persistent: true, wantErr: true, wantLogins: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := &keyServer{valid: map[string]bool{}}
+			srv := httptest.NewServer(box)
+			defer srv.Close()
+			c, err := gomiabdns.New(srv.URL, "admin@example.com", "password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Login(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if tt.expire {
+				box.mu.Lock()
+				box.valid = map[string]bool{}
+				box.rejectAll = tt.persistent
+				box.mu.Unlock()
+			}
+
+			_, err = c.GetHosts(context.Background(), "", "")
+			if tt.wantErr {
+				var apiErr *gomiabdns.APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+					t.Errorf("GetHosts error = %v, want a 403 *APIError", err)
+				}
+			} else if err != nil {
+				t.Errorf("GetHosts: %v", err)
+			}
+			if box.logins != tt.wantLogins {
+				t.Errorf("got %d logins, want %d", box.logins, tt.wantLogins)
+			}
+		})
+	}
+}
+
+// loginServer is a box whose login endpoint always sends body.
+func loginServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/login" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestLoginMissingTOTPToken(t *testing.T) {
+	// The response of a box to a login without a code for an account with two-factor authentication.
+	srv := loginServer(`{"status": "missing-totp-token", "reason": "missing-totp-token"}`)
+	defer srv.Close()
+	c, err := gomiabdns.New(srv.URL, "admin@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Login(context.Background())
+	if !errors.Is(err, gomiabdns.ErrTOTPRequired) {
+		t.Errorf("Login error = %v, want ErrTOTPRequired", err)
+	}
+	if c.APIKey() != "" {
+		t.Errorf("APIKey() = %q after a failed login, want none", c.APIKey())
+	}
+}
+
+func TestLoginNotAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		privileges []string
+		wantErr    error
+	}{
+		{name: "admin", privileges: []string{"admin"}},
+		{name: "admin among others", privileges: []string{"other", "admin"}},
+		{name: "mail user", wantErr: gomiabdns.ErrNotAdmin},
+		{name: "other privilege", privileges: []string{"other"}, wantErr: gomiabdns.ErrNotAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := miabtest.NewServer("example.com")
+			defer s.Close()
+			s.SetTOTPSecret(totpSecret)
+			s.SetPrivileges(tt.privileges...)
+			c, err := gomiabdns.NewWithOptions(s.APIURL(), miabtest.Email, miabtest.Password, gomiabdns.WithTOTPSecret(totpSecret))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Login(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Login error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				return
+			}
+			// A client with two-factor authentication logs in before its first request, which
+			// fails the same way without reaching the DNS api.
+			if _, err := c.GetHosts(context.Background(), "", ""); !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetHosts error = %v, want %v", err, tt.wantErr)
+			}
+			if got := len(s.RequestsTo(http.MethodGet, "/admin/dns/custom")); got != 0 {
+				t.Errorf("GetHosts sent %d requests to the DNS api, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package gomiabdns
+
+import (
+	"context"
+	"sync"
+)
+
+// AddMany adds each of records with AddHost. Only the QualifiedName, RecordType and Value fields are used.
+// The returned slice is aligned with records and holds the result of each addition, so one bad record
+// doesn't stop the rest. The second return value is only set if the batch could not start at all,
+// for ex. because the login failed: a client with a password logs in first, so a wrong password
+// or missing two-factor authentication code isn't reported as the error of every record.
+// Up to BatchConcurrency records are added at once.
+func (c *Client) AddMany(ctx context.Context, records []DNSRecord) ([]error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := c.ensureLoggedIn(ctx); err != nil {
+		return nil, err
+	}
+	return c.forEach(ctx, len(records), func(ctx context.Context, i int) error {
+		_, err := c.AddHost(ctx, records[i].QualifiedName, records[i].RecordType, records[i].Value)
+		return err
+	}), nil
+}
+
+// forEach calls fn for every index below n, running up to BatchConcurrency calls at once,
+// and returns their errors in index order.
+func (c *Client) forEach(ctx context.Context, n int, fn func(ctx context.Context, i int) error) []error {
+	errs := make([]error, n)
+	workers := c.BatchConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,77 @@
+package gomiabdns_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luv2code/gomiabdns"
+	"github.com/luv2code/gomiabdns/miabtest"
+)
+
+func TestAddManyLoginFailure(t *testing.T) {
+	records := []gomiabdns.DNSRecord{
+		{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.4"},
+		{QualifiedName: "mail.example.com", RecordType: gomiabdns.A, Value: "1.2.3.5"},
+	}
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Incorrect username or password", http.StatusUnauthorized)
+	}))
+	defer unauthorized.Close()
+	box := miabtest.NewServer("example.com")
+	defer box.Close()
+	tests := []struct {
+		name     string
+		url      string
+		password string
+	}{
+		{name: "401 login", url: unauthorized.URL, password: "password"},
+		{name: "wrong password", url: box.APIURL(), password: "wrong"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := gomiabdns.New(tt.url, miabtest.Email, tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			errs, err := c.AddMany(context.Background(), records)
+			if err == nil {
+				t.Fatalf("AddMany returned %v and no error for a failed login", errs)
+			}
+			if errs != nil {
+				t.Errorf("AddMany returned the record errors %v, want none after a failed login", errs)
+			}
+		})
+	}
+	if got := len(box.RequestsTo(http.MethodPost, "/admin/dns/custom/www.example.com/A")); got != 0 {
+		t.Errorf("AddMany sent %d records after a failed login, want none", got)
+	}
+}
+
+func TestAddMany(t *testing.T) {
+	s := miabtest.NewServer("example.com")
+	defer s.Close()
+	c, err := gomiabdns.NewWithOptions(s.APIURL(), miabtest.Email, miabtest.Password, gomiabdns.WithTOTPSecret(totpSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetTOTPSecret(totpSecret)
+	errs, err := c.AddMany(context.Background(), []gomiabdns.DNSRecord{
+		{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.4"},
+		{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "not an address"},
+		{QualifiedName: "mail.example.com", RecordType: gomiabdns.A, Value: "1.2.3.5"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs[0] != nil || errs[1] == nil || errs[2] != nil {
+		t.Errorf("AddMany returned %v, want only the second record to fail", errs)
+	}
+	if got := len(s.Records()); got != 2 {
+		t.Errorf("the box has %d records, want 2", got)
+	}
+	if got := len(s.RequestsTo(http.MethodPost, "/admin/login")); got != 1 {
+		t.Errorf("got %d logins, want 1", got)
+	}
+}
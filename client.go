@@ -2,15 +2,28 @@
 package gomiabdns
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/time/rate"
 )
 
+// Version is the version of this package, sent in the default User-Agent header. Release builds
+// set it with -ldflags "-X github.com/luv2code/gomiabdns.Version=v1.2.3".
+var Version = "dev"
+
 // RecordType is the type of DNS Record. For ex. CNAME.
 type RecordType string
 
@@ -35,116 +48,740 @@ const (
 	SSHFP RecordType = "SSHFP"
 )
 
+// DNSClient is the set of record and zone operations Client provides. Code that depends on
+// DNSClient instead of *Client can be tested with a fake implementation.
+type DNSClient interface {
+	GetHosts(ctx context.Context, name string, recordType RecordType) ([]DNSRecord, error)
+	AddHost(ctx context.Context, name string, recordType RecordType, value string) (MutationResult, error)
+	UpdateHost(ctx context.Context, name string, recordType RecordType, value string) (MutationResult, error)
+	DeleteHost(ctx context.Context, name string, recordType RecordType, value string) (MutationResult, error)
+	GetZones(ctx context.Context) ([]string, error)
+	GetZonefile(ctx context.Context, zone string) (string, error)
+}
+
+var _ DNSClient = (*Client)(nil)
+
 // Client provides a target for methods interacting with the DNS API.
+// A Client is safe for concurrent use by multiple goroutines as long as its
+// fields are not modified after it is created.
 type Client struct {
 	ApiUrl *url.URL
+	// AdminUrl is the url of the box's /admin api, which login, zones and every endpoint besides
+	// custom DNS are under. When nil, it is ApiUrl without its /dns/custom suffix, which keeps any
+	// path prefix a reverse proxy adds. Set it when a proxy mounts the two somewhere else.
+	AdminUrl *url.URL
+	// HTTPClient is used for all requests to the API. When nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// Timeout bounds each request made to the API. The default of zero means no timeout
+	// beyond whatever the context passed to a method imposes.
+	Timeout time.Duration
+	// MaxResponseSize is the most bytes of a response body the client reads into memory. Larger
+	// responses fail with an error wrapping ErrResponseTooLarge instead, and the bodies of error
+	// responses are cut off at it. ForEachHost, which doesn't keep the body in memory, isn't
+	// limited. The default of zero means DefaultMaxResponseSize.
+	MaxResponseSize int64
+	// Retry controls retrying of requests that fail with a transient error. The zero value
+	// disables retries.
+	Retry RetryPolicy
+	// SkipValidation disables the checks AddHost and UpdateHost make on record values before
+	// sending them. Set it if the box accepts a format that ValidateValue rejects.
+	SkipValidation bool
+	// BatchConcurrency is how many requests batch methods like AddMany run at once. Values
+	// below 2 run them one at a time.
+	BatchConcurrency int
+	// Order is the order GetHosts and the methods built on it return records in. The default,
+	// OrderAsReturned, keeps the API's order. ForEachHost always passes records in the API's order.
+	Order RecordOrder
+	// CheckZones makes AddHost and UpdateHost check that the name is in a zone the box serves
+	// before sending anything, catching typos in the domain. The zones are fetched once and
+	// cached, call ResetZoneCache after adding a domain to the box.
+	CheckZones bool
+	// DryRun makes AddHost, UpdateHost and DeleteHost validate their parameters and log the request
+	// they would send to Logger, at the info level, without sending it. Set a Logger to see them.
+	DryRun bool
+	// NoChangeError makes AddHost, UpdateHost, DeleteHost and the methods built on them return
+	// ErrNoChange along with their MutationResult when the box reports that the records already
+	// matched the request, for tools that only look at the error to tell "in sync" from "updated".
+	NoChangeError bool
+	// Logger, when set, receives a debug record for every request with its method, url, status code,
+	// duration and the id sent in its X-Request-ID header, plus the one the box sent back if it did,
+	// to find the request in the box's logs. Credentials are never logged.
+	Logger *slog.Logger
+	// UserAgent is sent with every request. When empty, "gomiabdns/" followed by Version is sent.
+	UserAgent string
+	// TOTPSecret is the base32 encoded secret of the account's two-factor authentication, for
+	// boxes that require it. Login sends a code generated from it, and the client logs in before
+	// its first request, since the box rejects a code that was already used.
+	TOTPSecret string
+	// TOTPCode is a code from the account's authenticator app, sent when logging in instead of one
+	// generated from TOTPSecret. The box accepts a code once, so the client logs in with it before
+	// its first request, and it is of no use once the api key that login returned is gone.
+	// A secret is convenient, the client can log in again whenever it needs to, but anyone who
+	// reads it can generate codes forever. A code keeps the secret on the user's device and is
+	// worthless after a minute, at the cost of typing it in for every session.
+	TOTPCode string
+	// Headers are added to every request, for ex. a token an authenticating proxy in front of the
+	// box requires. The Authorization, User-Agent, Content-Type and X-Request-ID headers the client
+	// sets take precedence over any of the same name here.
+	Headers http.Header
+	// Metrics, when set, is told about every request with its method, status code and duration.
+	Metrics Metrics
+	// RateLimit is the most requests per second sent to the box, retries included. Requests over
+	// the limit wait for their turn, or fail right away if their context would be done first. The
+	// default of zero means no limit.
+	RateLimit float64
+	// RateBurst is how many requests may be sent at once before RateLimit applies. Values below 1
+	// allow a single request.
+	RateBurst int
+
+	// InsecureSkipVerify turns off verification of the box's TLS certificate. This is dangerous:
+	// anyone between the client and the box can read the credentials. It is only meant for
+	// bootstrapping a fresh box that still has its self-signed certificate, and has no effect
+	// when HTTPClient is set.
+	InsecureSkipVerify bool
+	// RootCAs, when set, are the only certificate authorities trusted for the box's certificate.
+	// It has no effect when HTTPClient is set.
+	RootCAs *x509.CertPool
+
+	mu     sync.Mutex
+	apikey string
+
+	internalClientOnce sync.Once
+	internalClient     *http.Client
+
+	rateLimiterOnce sync.Once
+	rateLimiter     *rate.Limiter
+
+	zonesMu sync.Mutex
+	zones   []string
+
+	// now returns the current time for TOTP codes and Retry-After dates. See WithClock.
+	now func() time.Time
+	// after waits for retry backoffs and Retry-After delays; time.After when nil. Tests replace it
+	// to check the waits without sleeping.
+	after func(time.Duration) <-chan time.Time
 }
 
-// New returns a new client ready to call the provided endpoint.
-func New(apiUrl, email, password string) *Client {
-	parsedUrl, err := url.Parse(apiUrl)
-	parsedUrl.User = url.UserPassword(email, password)
+// New returns a new client ready to call the provided endpoint, the box's custom DNS api, for ex.
+// https://box.example.com/admin/dns/custom. The url of the box itself or of its /admin api is
+// completed to that. An error is returned if apiUrl is not an absolute http or https URL, or has
+// another path.
+func New(apiUrl, email, password string) (*Client, error) {
+	return NewWithOptions(apiUrl, email, password)
+}
+
+// NewWithHTTPClient returns a new client that sends its requests through httpClient.
+// Use this to configure timeouts, proxies or custom TLS settings. If httpClient is nil,
+// http.DefaultClient is used.
+func NewWithHTTPClient(apiUrl, email, password string, httpClient *http.Client) (*Client, error) {
+	return NewWithOptions(apiUrl, email, password, WithHTTPClient(httpClient))
+}
+
+// NewWithAPIKey returns a new client that authenticates with an api key, as returned by Login or
+// APIKey, instead of a password. Two-factor authentication isn't needed in this mode, the key was
+// issued after it. The client can't log in again, so once the box stops accepting the key,
+// requests fail with an error wrapping ErrAPIKeyRejected and a new client is needed.
+func NewWithAPIKey(apiUrl, email, apiKey string, opts ...Option) (*Client, error) {
+	if email == "" || apiKey == "" {
+		return nil, fmt.Errorf("Missing parameters to NewWithAPIKey. all are required. email: %s", email)
+	}
+	c, err := NewWithOptions(apiUrl, email, "", opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.ApiUrl.User = url.User(email)
+	c.apikey = apiKey
+	return c, nil
+}
+
+// NewWithOptions returns a new client ready to call the provided endpoint, configured by opts.
+// apiUrl is checked and completed as New does, except that any path is accepted along with
+// WithAdminUrl.
+func NewWithOptions(apiUrl, email, password string, opts ...Option) (*Client, error) {
+	parsedUrl, err := parseApiUrl(apiUrl)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return &Client{
+	parsedUrl.User = url.UserPassword(email, password)
+	parsedUrl.Path = canonicalApiPath(parsedUrl.Path)
+	parsedUrl.RawPath = ""
+	c := &Client{
 		ApiUrl: parsedUrl,
 	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	if c.AdminUrl == nil && !strings.HasSuffix(parsedUrl.Path, customDNSPath) {
+		return nil, fmt.Errorf("Invalid api url %q: expected the custom DNS api of the box, for ex. "+
+			"https://box.example.com/admin/dns/custom, or WithAdminUrl for a proxy that mounts it elsewhere", apiUrl)
+	}
+	return c, nil
+}
+
+// clock returns the current time, from the clock set by WithClock if there is one.
+func (c *Client) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
 }
 
 // GetHosts returns all defined records if name and recordType are both empty string.
 // If values are provided for both name and recordType, only the records that match both are returned.
 // If one or the other of name and recordType are empty string, no records are returned.
+// The records are in the API's order unless Client.Order says otherwise.
 func (c *Client) GetHosts(ctx context.Context, name string, recordType RecordType) ([]DNSRecord, error) {
 	apiUrl := getApiWithPath(c.ApiUrl, name, recordType)
-	apiResp, err := doRequest(ctx, http.MethodGet, apiUrl.String(), "")
+	apiResp, err := c.doRequest(ctx, http.MethodGet, apiUrl.String(), "")
+	if err != nil {
+		return nil, err
+	}
+	records, err := unmarshalRecords(apiResp)
+	if err != nil {
+		return nil, err
+	}
+	sortRecords(records, c.Order)
+	return records, nil
+}
+
+// GetHostsMap returns the records GetHosts returns, grouped by QualifiedName. The records of a name
+// keep the order GetHosts returns them in.
+func (c *Client) GetHostsMap(ctx context.Context, name string, recordType RecordType) (map[string][]DNSRecord, error) {
+	records, err := c.GetHosts(ctx, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string][]DNSRecord{}
+	for _, record := range records {
+		result[record.QualifiedName] = append(result[record.QualifiedName], record)
+	}
+	return result, nil
+}
+
+// GetRecord returns the record whose name, record type and value all equal the ones given, or an
+// error wrapping ErrRecordNotFound if there is none. Names, and the values of CNAME and NS records,
+// are compared as NormalizeName does, other values exactly.
+func (c *Client) GetRecord(ctx context.Context, name string, recordType RecordType, value string) (*DNSRecord, error) {
+	if name == "" || recordType == "" || value == "" {
+		return nil, fmt.Errorf(
+			"Missing parameters to GetRecord. all are required. name: %s, recordType: %s, value: %s ",
+			name,
+			recordType,
+			value,
+		)
+	}
+	records, err := c.GetHosts(ctx, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		if NormalizeName(records[i].QualifiedName) == NormalizeName(name) && records[i].RecordType == recordType &&
+			SameValue(recordType, records[i].Value, value) {
+			return &records[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s %s %s: %w", name, recordType, value, ErrRecordNotFound)
+}
+
+// GetAllRecords returns every custom record defined on the box. It is the same as calling
+// GetHosts with an empty name and record type.
+func (c *Client) GetAllRecords(ctx context.Context) ([]DNSRecord, error) {
+	return c.GetHosts(ctx, "", "")
+}
+
+// GetRecordsForName returns the records of any type whose name is name. GetHosts can't do this,
+// since the API only filters by name and record type together, so all records are fetched and
+// filtered here.
+func (c *Client) GetRecordsForName(ctx context.Context, name string) ([]DNSRecord, error) {
+	if name == "" {
+		return nil, fmt.Errorf("Missing parameter to GetRecordsForName. name is required.")
+	}
+	records, err := c.GetAllRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return FilterByName(records, name), nil
+}
+
+// GetRecordTypesForName returns the distinct types of the records whose name is name, sorted
+// alphabetically. Like GetRecordsForName, it fetches all records.
+func (c *Client) GetRecordTypesForName(ctx context.Context, name string) ([]RecordType, error) {
+	if name == "" {
+		return nil, fmt.Errorf("Missing parameter to GetRecordTypesForName. name is required.")
+	}
+	records, err := c.GetRecordsForName(ctx, name)
 	if err != nil {
 		return nil, err
 	}
-	return unmarshalRecords(apiResp)
+	types := recordTypes(records)
+	slices.Sort(types)
+	return types, nil
+}
+
+// GetRecordsCreatedAfter returns the records whose SortOrder.ByCreated is greater than index, in
+// the order they were created, so a caller can poll for new records by passing the highest
+// ByCreated it has seen. The API has no timestamps, ByCreated is the record's position in the
+// box's list of custom records, so this only detects creations, not edits. UpdateHost replaces
+// a record, which shows up as a creation. Deleting a record moves the ones created after it
+// up the list, which can hide records created later, so fetch everything again after deletions.
+func (c *Client) GetRecordsCreatedAfter(ctx context.Context, index int) ([]DNSRecord, error) {
+	records, err := c.GetAllRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records = filterRecords(records, func(r DNSRecord) bool { return r.SortOrder.ByCreated > index })
+	SortByCreated(records)
+	return records, nil
+}
+
+// GetHostsByZone returns all defined records that belong to zone. The API can't filter by zone,
+// so all records are fetched and filtered here.
+func (c *Client) GetHostsByZone(ctx context.Context, zone string) ([]DNSRecord, error) {
+	if zone == "" {
+		return nil, fmt.Errorf("Missing parameter to GetHostsByZone. zone is required.")
+	}
+	records, err := c.GetAllRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return FilterByZone(records, zone), nil
 }
 
 // AddHost adds a record. name, recordType, and value are all required. If a record exists with the same value,
-// no new record is created. Use this method for creating multple A records for dns loadbalancing. Or use it
-// to create multiple different TXT records.
-func (c *Client) AddHost(ctx context.Context, name string, recordType RecordType, value string) error {
+// no new record is created. Use this method for creating multple A records for dns loadbalancing, or
+// SetARecords to set all of them at once. Or use it to create multiple different TXT records.
+func (c *Client) AddHost(ctx context.Context, name string, recordType RecordType, value string) (MutationResult, error) {
 	if name == "" || recordType == "" || value == "" {
-		return fmt.Errorf(
+		return MutationResult{}, fmt.Errorf(
 			"Missing parameters to AddHost. all are required. name: %s, recordType: %s, value: %s ",
 			name,
 			recordType,
 			value,
 		)
 	}
-	apiUrl := getApiWithPath(c.ApiUrl, name, recordType)
-	apiResp, err := doRequest(ctx, http.MethodPost, apiUrl.String(), value)
-	if err != nil {
-		return err
+	if !c.SkipValidation {
+		if err := ValidateValue(recordType, value); err != nil {
+			return MutationResult{}, err
+		}
 	}
-	fmt.Println(string(apiResp))
-	return nil
+	if err := c.checkZone(ctx, name); err != nil {
+		return MutationResult{}, err
+	}
+	apiUrl := getApiWithPath(c.ApiUrl, name, recordType)
+	return c.doMutation(ctx, http.MethodPost, apiUrl.String(), value)
 }
 
 // UpdateHost will create or update a record that corresponds with the name and recordType.
 // If multiple records with the same name and type exists, they will all be removed and replaced
 // with a single one that matches the parameters passed to this method. name, recordType, and value
 // are all required.
-func (c *Client) UpdateHost(ctx context.Context, name string, recordType RecordType, value string) error {
+func (c *Client) UpdateHost(ctx context.Context, name string, recordType RecordType, value string) (MutationResult, error) {
 	if name == "" || recordType == "" || value == "" {
-		return fmt.Errorf(
+		return MutationResult{}, fmt.Errorf(
 			"Missing parameters to UpdateHost. all are required. name: %s, recordType: %s, value: %s ",
 			name,
 			recordType,
 			value,
 		)
 	}
-	apiUrl := getApiWithPath(c.ApiUrl, name, recordType)
-	apiResp, err := doRequest(ctx, http.MethodPut, apiUrl.String(), value)
-	if err != nil {
-		return err
+	if !c.SkipValidation {
+		if err := ValidateValue(recordType, value); err != nil {
+			return MutationResult{}, err
+		}
 	}
-	fmt.Println(string(apiResp))
-	return nil
+	if err := c.checkZone(ctx, name); err != nil {
+		return MutationResult{}, err
+	}
+	apiUrl := getApiWithPath(c.ApiUrl, name, recordType)
+	return c.doMutation(ctx, http.MethodPut, apiUrl.String(), value)
 }
 
 // DeleteHost will delete records that match the passed paramters.
-func (c *Client) DeleteHost(ctx context.Context, name string, recordType RecordType, value string) error {
+//
+// Beware: with an empty value, DeleteHost deletes every recordType record of name, not one of
+// them. Use DeleteExact to delete a single record.
+func (c *Client) DeleteHost(ctx context.Context, name string, recordType RecordType, value string) (MutationResult, error) {
 	if name == "" {
-		return fmt.Errorf("Missing parameter to DeleteHost. Name is required. name: %s", name)
+		return MutationResult{}, fmt.Errorf("Missing parameter to DeleteHost. Name is required. name: %s", name)
 	}
 	apiUrl := getApiWithPath(c.ApiUrl, name, recordType)
-	apiResp, err := doRequest(ctx, http.MethodDelete, apiUrl.String(), value)
+	return c.doMutation(ctx, http.MethodDelete, apiUrl.String(), value)
+}
+
+// DeleteExact deletes the single record with name, recordType and value. Unlike DeleteHost, it
+// returns an error rather than deleting every record of name and recordType when value is empty.
+func (c *Client) DeleteExact(ctx context.Context, name string, recordType RecordType, value string) (MutationResult, error) {
+	if name == "" || recordType == "" || value == "" {
+		return MutationResult{}, fmt.Errorf(
+			"Missing parameters to DeleteExact. all are required. name: %s, recordType: %s, value: %s ",
+			name,
+			recordType,
+			value,
+		)
+	}
+	return c.DeleteHost(ctx, name, recordType, value)
+}
+
+// EnsureHost makes sure name has a recordType record with value. If it already does, nothing is
+// changed. If there are no such records yet, the record is added. If there are records with other
+// values, they are replaced by this one, as UpdateHost does. changed reports whether a record was
+// added or updated, or in dry run mode, would have been. The values of CNAME and NS records are
+// compared as NormalizeName does, so a trailing dot or different case doesn't cause an update.
+func (c *Client) EnsureHost(ctx context.Context, name string, recordType RecordType, value string) (changed bool, err error) {
+	if name == "" || recordType == "" || value == "" {
+		return false, fmt.Errorf(
+			"Missing parameters to EnsureHost. all are required. name: %s, recordType: %s, value: %s ",
+			name,
+			recordType,
+			value,
+		)
+	}
+	existing, err := c.GetHosts(ctx, name, recordType)
+	if err != nil {
+		return false, err
+	}
+	for _, record := range existing {
+		if SameValue(recordType, record.Value, value) {
+			return false, nil
+		}
+	}
+	if len(existing) == 0 {
+		_, err = c.AddHost(ctx, name, recordType, value)
+	} else {
+		_, err = c.UpdateHost(ctx, name, recordType, value)
+	}
+	if errors.Is(err, ErrNoChange) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetARecords makes the A records of name exactly ips, for ex. to balance load over several
+// servers. Missing addresses are added before extra ones are deleted, so the name keeps
+// resolving throughout, but the box can't swap the set in one request: if a request fails,
+// the records are left part way. Every ip must be an IPv4 address and at least one is required,
+// use DeleteHost to remove them all.
+func (c *Client) SetARecords(ctx context.Context, name string, ips []string) error {
+	if name == "" || len(ips) == 0 {
+		return fmt.Errorf("Missing parameters to SetARecords. name and at least one ip are required. name: %s, ips: %v", name, ips)
+	}
+	for _, ip := range ips {
+		if err := ValidateValue(A, ip); err != nil {
+			return err
+		}
+	}
+	existing, err := c.GetHosts(ctx, name, A)
 	if err != nil {
 		return err
 	}
-	fmt.Println(string(apiResp))
+	have := make([]string, len(existing))
+	for i, record := range existing {
+		have[i] = record.Value
+	}
+	for _, ip := range ips {
+		if slices.Contains(have, ip) {
+			continue
+		}
+		if _, err := c.AddHost(ctx, name, A, ip); err != nil && !errors.Is(err, ErrNoChange) {
+			return err
+		}
+		have = append(have, ip)
+	}
+	for _, value := range have {
+		if slices.Contains(ips, value) {
+			continue
+		}
+		if _, err := c.DeleteExact(ctx, name, A, value); err != nil && !errors.Is(err, ErrNoChange) {
+			return err
+		}
+	}
 	return nil
 }
 
-func doRequest(ctx context.Context, method, requestURL, value string) ([]byte, error) {
+// DeleteAllForName deletes every record, of any type, whose name is name. Deletion continues past
+// failures and the errors of all failed deletions are returned joined together.
+// Use PlanDeleteAllForName to see what would be deleted.
+func (c *Client) DeleteAllForName(ctx context.Context, name string) error {
+	records, err := c.PlanDeleteAllForName(ctx, name)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, recordType := range recordTypes(records) {
+		if _, err := c.DeleteHost(ctx, name, recordType, ""); err != nil && !errors.Is(err, ErrNoChange) {
+			errs = append(errs, fmt.Errorf("deleting %s records of %s: %w", recordType, name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PlanDeleteAllForName returns the records DeleteAllForName would delete, without deleting anything.
+func (c *Client) PlanDeleteAllForName(ctx context.Context, name string) ([]DNSRecord, error) {
+	return c.GetRecordsForName(ctx, name)
+}
+
+// doMutation sends a request that changes records on the box and parses the box's reply, unless
+// the client is in dry run mode, in which case the request is only logged.
+func (c *Client) doMutation(ctx context.Context, method, requestURL, value string) (MutationResult, error) {
+	if c.DryRun {
+		c.logDryRun(ctx, method, requestURL, value)
+		return MutationResult{}, nil
+	}
+	body, err := c.doRequest(ctx, method, requestURL, value)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	c.logResponse(ctx, method, requestURL, body)
+	result := parseMutationResult(body)
+	if c.NoChangeError && !result.Changed {
+		return result, ErrNoChange
+	}
+	return result, nil
+}
+
+// doRequest sends a request authenticated with the cached api key, or with the password if
+// the client hasn't logged in.
+func (c *Client) doRequest(ctx context.Context, method, requestURL, value string) ([]byte, error) {
+	return c.sendAuthenticated(ctx, apiRequest{method: method, url: requestURL, value: value})
+}
+
+// doFormRequest sends form as a url encoded request body.
+func (c *Client) doFormRequest(ctx context.Context, method, requestURL string, form url.Values) ([]byte, error) {
+	return c.sendAuthenticated(ctx, apiRequest{
+		method:      method,
+		url:         requestURL,
+		contentType: "application/x-www-form-urlencoded",
+		value:       form.Encode(),
+	})
+}
+
+// sendAuthenticated sends req with the secret requestSecret returns and returns the body of the response.
+func (c *Client) sendAuthenticated(ctx context.Context, req apiRequest) ([]byte, error) {
+	var body []byte
+	err := c.withAuthentication(ctx, req, func(req apiRequest) error {
+		var err error
+		body, err = c.send(ctx, req)
+		return err
+	})
+	return body, err
+}
+
+// apiRequest describes a single call to the API.
+type apiRequest struct {
+	// secret is sent as the basic auth password along with the client's email.
+	secret string
+	// authToken, when set, is sent as the x-auth-token header: the one-time code of accounts
+	// with two-factor authentication.
+	authToken string
+	method    string
+	url       string
+	// contentType is only sent when it isn't empty.
+	contentType string
+	value       string
+	// requestID is sent as the X-Request-ID header of every attempt, so the retries of a request
+	// share its id in the box's logs.
+	requestID string
+}
+
+// send sends req, retrying it according to the retry policy, and returns the response body.
+func (c *Client) send(ctx context.Context, req apiRequest) ([]byte, error) {
+	var body []byte
+	req.requestID = newRequestID()
+	err := c.withRetries(ctx, req.method, func() error {
+		return c.doAttempt(ctx, req, func(r io.Reader) error {
+			var err error
+			body, err = c.readBody(r)
+			return err
+		})
+	})
+	return body, err
+}
+
+// withRetries calls attempt until it succeeds or fails in a way the retry policy doesn't retry.
+func (c *Client) withRetries(ctx context.Context, method string, attempt func() error) error {
+	retriedTooManyRequests := false
+	for n := 1; ; {
+		err := attempt()
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			return permanent.error
+		}
+		if delay, ok := c.Retry.tooManyRequests(err); ok && !retriedTooManyRequests {
+			retriedTooManyRequests = true
+			if err := c.sleep(ctx, delay); err != nil {
+				return err
+			}
+			continue
+		}
+		if err == nil || n >= c.Retry.MaxAttempts || !isRetryable(ctx, method, err) {
+			return err
+		}
+		if err := c.sleep(ctx, c.Retry.backoff(n)); err != nil {
+			return err
+		}
+		n++
+	}
+}
+
+// doAttempt sends req once and hands the body of a successful response to read.
+func (c *Client) doAttempt(ctx context.Context, req apiRequest, read func(io.Reader) error) error {
+	if err := c.waitRateLimit(ctx); err != nil {
+		return err
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
 	var r io.Reader
-	if value != "" {
-		r = strings.NewReader(value)
+	if req.value != "" {
+		r = strings.NewReader(req.value)
 	}
-	req, err := http.NewRequestWithContext(ctx, method, requestURL, r)
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, req.url, r)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	for key, values := range c.Headers {
+		httpReq.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	httpReq.SetBasicAuth(c.ApiUrl.User.Username(), req.secret)
+	if req.authToken != "" {
+		httpReq.Header.Set("x-auth-token", req.authToken)
+	}
+	httpReq.Header.Set("User-Agent", c.userAgent())
+	requestID := req.requestID
+	if requestID != "" {
+		httpReq.Header.Set("X-Request-ID", requestID)
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	start := time.Now()
+	resp, err := c.httpClient().Do(httpReq)
 	if err != nil {
-		return nil, err
+		c.finishRequest(ctx, req, requestID, nil, start, err)
+		return err
+	}
+	defer drainAndClose(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxResponseSize()))
+		c.finishRequest(ctx, req, requestID, resp, start, err)
+		if err != nil {
+			return err
+		}
+		apiErr := newAPIError(resp.StatusCode, body)
+		apiErr.RequestID = requestID
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), c.clock())
+		}
+		if resp.StatusCode == http.StatusUnauthorized && !c.hasPassword() {
+			return fmt.Errorf("%w, create the client with a new one: %w", ErrAPIKeyRejected, apiErr)
+		}
+		return apiErr
+	}
+	err = read(resp.Body)
+	c.finishRequest(ctx, req, requestID, resp, start, err)
+	return err
+}
+
+// finishRequest logs a request and reports it to the client's Metrics. resp is nil if the request
+// failed before a response was received.
+func (c *Client) finishRequest(ctx context.Context, req apiRequest, requestID string, resp *http.Response, start time.Time, err error) {
+	duration := time.Since(start)
+	status, responseID := 0, ""
+	if resp != nil {
+		status, responseID = resp.StatusCode, resp.Header.Get("X-Request-ID")
 	}
+	c.logRequest(ctx, req.method, req.url, requestID, responseID, status, duration, err)
+	c.observeRequest(req.method, status, duration)
+}
+
+// DefaultMaxResponseSize is the MaxResponseSize of a client that doesn't set one, far more than the
+// box sends for the records of even a large installation.
+const DefaultMaxResponseSize = 32 << 20
 
-	body, err := io.ReadAll(resp.Body)
+func (c *Client) maxResponseSize() int64 {
+	if c.MaxResponseSize > 0 {
+		return c.MaxResponseSize
+	}
+	return DefaultMaxResponseSize
+}
+
+// readBody reads the body of a successful response, failing if it is larger than MaxResponseSize.
+func (c *Client) readBody(r io.Reader) ([]byte, error) {
+	limit := c.maxResponseSize()
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
 	if err != nil {
 		return nil, err
 	}
-	if err = resp.Body.Close(); err != nil {
-		return nil, err
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%w: the box sent more than %d bytes", ErrResponseTooLarge, limit)
 	}
 	return body, nil
 }
 
+// maxDrain is the most of an unread body drainAndClose reads. Reading more to reuse the connection
+// costs more than opening a new one.
+const maxDrain = 64 << 10
+
+// drainAndClose reads what is left of body, up to maxDrain, before closing it, so the connection
+// can be reused.
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrain))
+	_ = body.Close()
+}
+
+func (c *Client) userAgent() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+	return "gomiabdns/" + Version
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	c.internalClientOnce.Do(func() {
+		c.internalClient = c.newInternalClient()
+	})
+	return c.internalClient
+}
+
+func parseApiUrl(apiUrl string) (*url.URL, error) {
+	parsedUrl, err := url.Parse(apiUrl)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid api url %q: %w", apiUrl, err)
+	}
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		return nil, fmt.Errorf("Invalid api url %q: scheme must be http or https", apiUrl)
+	}
+	if parsedUrl.Host == "" {
+		return nil, fmt.Errorf("Invalid api url %q: host is missing", apiUrl)
+	}
+	return parsedUrl, nil
+}
+
+// customDNSPath is where the custom DNS api is under the box's /admin api.
+const customDNSPath = "/dns/custom"
+
+// canonicalApiPath completes the path of the box's url, or of its /admin api, to the custom DNS
+// api under it, a common mistake that otherwise makes every request fail with 404 Not Found.
+// Trailing slashes are removed.
+func canonicalApiPath(path string) string {
+	path = strings.TrimRight(path, "/")
+	switch {
+	case path == "":
+		return "/admin" + customDNSPath
+	case strings.HasSuffix(path, "/admin"):
+		return path + customDNSPath
+	}
+	return path
+}
+
 func getApiWithPath(apiUrl *url.URL, name string, rtype RecordType) *url.URL {
 	if name != "" {
 		if rtype != "" {
@@ -156,14 +793,73 @@ func getApiWithPath(apiUrl *url.URL, name string, rtype RecordType) *url.URL {
 	return apiUrl
 }
 
+// recordTypes returns the distinct record types of records in the order they first appear.
+func recordTypes(records []DNSRecord) []RecordType {
+	var result []RecordType
+	seen := map[RecordType]bool{}
+	for _, record := range records {
+		if !seen[record.RecordType] {
+			seen[record.RecordType] = true
+			result = append(result, record.RecordType)
+		}
+	}
+	return result
+}
+
 func unmarshalRecords(data []byte) ([]DNSRecord, error) {
 	var result []DNSRecord
-	if err := json.Unmarshal(data, &result); err != nil {
+	if err := unmarshalJSON(data, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
+// unmarshalJSON decodes the body of a successful response into v. Bodies that are empty or an
+// HTML page, as a misconfigured proxy may send, are reported as such in an *APIError rather
+// than as a json syntax error.
+func unmarshalJSON(data []byte, v any) error {
+	var reason string
+	var decodeErr error
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) == 0:
+		reason = "empty response, expected JSON"
+	case trimmed[0] == '<':
+		reason = "got an HTML page, expected JSON. Check the api url"
+	default:
+		decodeErr = json.Unmarshal(trimmed, v)
+		if decodeErr == nil {
+			return nil
+		}
+		reason = "Error while decoding json: " + decodeErr.Error()
+	}
+	// doRequest only hands back the bodies of successful responses.
+	return fmt.Errorf("unexpected response from API: %w", &APIError{
+		StatusCode: http.StatusOK,
+		Reason:     reason,
+		Body:       data,
+		err:        decodeErr,
+	})
+}
+
+// MutationResult is the box's reply to a request that adds, updates or deletes records.
+type MutationResult struct {
+	// Message is the box's own description of what it did, for ex. "updated DNS: example.com".
+	// It is "OK" when the records already matched the request.
+	Message string
+	// Changed reports whether the box rewrote any DNS. It is false when the records already
+	// matched the request and in dry run mode.
+	Changed bool
+}
+
+func parseMutationResult(body []byte) MutationResult {
+	message := strings.TrimSpace(string(body))
+	return MutationResult{
+		Message: message,
+		Changed: strings.HasPrefix(message, "updated DNS"),
+	}
+}
+
 // DNSRecord represents the host data returned from the API
 type DNSRecord struct {
 	QualifiedName string     `json:"qname"`
@@ -175,3 +871,10 @@ type DNSRecord struct {
 	Value string `json:"value"`
 	Zone  string `json:"zone"`
 }
+
+// String formats the record as a zone file style line: the qualified name, the record type and
+// the value, separated by single spaces. The API doesn't report TTLs, so none is included. This
+// field order will not change.
+func (r DNSRecord) String() string {
+	return fmt.Sprintf("%s %s %s", r.QualifiedName, r.RecordType, r.Value)
+}
@@ -0,0 +1,326 @@
+package gomiabdns_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luv2code/gomiabdns"
+	"github.com/luv2code/gomiabdns/miabtest"
+	"golang.org/x/exp/slices"
+)
+
+const totpSecret = "GEZDGNBVGY3TQOJQ"
+
+func TestConcurrentGetHosts(t *testing.T) {
+	s := miabtest.NewServer("example.com")
+	defer s.Close()
+	s.SetTOTPSecret(totpSecret)
+	s.SetRecords([]gomiabdns.DNSRecord{{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.4"}})
+	c, err := gomiabdns.NewWithOptions(s.APIURL(), miabtest.Email, miabtest.Password, gomiabdns.WithTOTPSecret(totpSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			records, err := c.GetHosts(context.Background(), "www.example.com", gomiabdns.A)
+			if err == nil && len(records) != 1 {
+				t.Errorf("GetHosts returned %d records, want 1", len(records))
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetHosts: %v", err)
+		}
+	}
+	if logins := len(s.RequestsTo(http.MethodPost, "/admin/login")); logins != 1 {
+		t.Errorf("got %d logins, want 1", logins)
+	}
+}
+
+func TestGetHostsByZone(t *testing.T) {
+	s := miabtest.NewServer("example.com", "example.org", "sub.example.com")
+	defer s.Close()
+	s.SetRecords([]gomiabdns.DNSRecord{
+		{QualifiedName: "example.com", RecordType: gomiabdns.MX, Value: "10 mail.example.com"},
+		{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.4"},
+		{QualifiedName: "www.example.org", RecordType: gomiabdns.A, Value: "1.2.3.5"},
+		{QualifiedName: "www.sub.example.com", RecordType: gomiabdns.A, Value: "1.2.3.6"},
+		{QualifiedName: "example.com.example.org", RecordType: gomiabdns.TXT, Value: "not example.com"},
+	})
+	c, err := gomiabdns.New(s.APIURL(), miabtest.Email, miabtest.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		zone string
+		want []string
+	}{
+		{"example.com", []string{"example.com", "www.example.com"}},
+		{"Example.COM.", []string{"example.com", "www.example.com"}},
+		{"example.org", []string{"www.example.org", "example.com.example.org"}},
+		{"sub.example.com", []string{"www.sub.example.com"}},
+		{"example.net", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			records, err := c.GetHostsByZone(context.Background(), tt.zone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range records {
+				got = append(got, r.QualifiedName)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("GetHostsByZone(%q) returned %q, want %q", tt.zone, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var requests, conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1)%3 == 0 {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		// ForEachHost stops reading at the end of the array, the rest must be drained.
+		_, _ = w.Write([]byte(`[{"qname": "www.example.com", "rtype": "A", "value": "1.2.3.4"}]`))
+		w.(http.Flusher).Flush()
+		time.Sleep(5 * time.Millisecond)
+		_, _ = w.Write([]byte(strings.Repeat(" ", 32<<10)))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+	c, err := gomiabdns.New(srv.URL, miabtest.Email, miabtest.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		_, _ = c.GetHosts(context.Background(), "", "")
+		_ = c.ForEachHost(context.Background(), "", "", func(gomiabdns.DNSRecord) error { return nil })
+	}
+	if got := requests.Load(); got != 20 {
+		t.Fatalf("got %d requests, want 20", got)
+	}
+	if got := conns.Load(); got != 1 {
+		t.Errorf("20 requests used %d connections, want 1", got)
+	}
+}
+
+func TestNewCompletesApiUrl(t *testing.T) {
+	s := miabtest.NewServer("example.com")
+	defer s.Close()
+	s.SetRecords([]gomiabdns.DNSRecord{{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.4"}})
+	tests := []struct {
+		name    string
+		apiUrl  string
+		wantErr bool
+	}{
+		{name: "custom dns api", apiUrl: s.URL + "/admin/dns/custom"},
+		{name: "custom dns api with trailing slash", apiUrl: s.URL + "/admin/dns/custom/"},
+		{name: "admin api", apiUrl: s.URL + "/admin"},
+		{name: "admin api with trailing slash", apiUrl: s.URL + "/admin/"},
+		{name: "box", apiUrl: s.URL},
+		{name: "box with trailing slash", apiUrl: s.URL + "/"},
+		{name: "other path", apiUrl: s.URL + "/admin/dns", wantErr: true},
+		{name: "no scheme", apiUrl: strings.TrimPrefix(s.URL, "http://"), wantErr: true},
+		{name: "other scheme", apiUrl: "ftp://box.example.com/admin", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := gomiabdns.New(tt.apiUrl, miabtest.Email, miabtest.Password)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("New(%q) succeeded with api url %s, want an error", tt.apiUrl, c.ApiUrl.Redacted())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.ApiUrl.Path != "/admin/dns/custom" {
+				t.Errorf("New(%q) has the api path %q, want /admin/dns/custom", tt.apiUrl, c.ApiUrl.Path)
+			}
+			records, err := c.GetHosts(context.Background(), "www.example.com", gomiabdns.A)
+			if err != nil || len(records) != 1 {
+				t.Errorf("GetHosts = %v, %v, want the record", records, err)
+			}
+		})
+	}
+}
+
+func TestNewCompletesApiUrlUnderPrefix(t *testing.T) {
+	for _, apiUrl := range []string{"https://example.com/miab/admin", "https://example.com/miab/admin/", "https://example.com/miab/admin/dns/custom"} {
+		c, err := gomiabdns.New(apiUrl, miabtest.Email, miabtest.Password)
+		if err != nil {
+			t.Errorf("New(%q): %v", apiUrl, err)
+		} else if got := c.ApiUrl.Path; got != "/miab/admin/dns/custom" {
+			t.Errorf("New(%q) has the api path %q, want /miab/admin/dns/custom", apiUrl, got)
+		}
+	}
+}
+
+func TestCancelInFlight(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(ctx context.Context, c *gomiabdns.Client) error
+	}{
+		{"GetHosts", func(ctx context.Context, c *gomiabdns.Client) error {
+			_, err := c.GetHosts(ctx, "", "")
+			return err
+		}},
+		{"ForEachHost", func(ctx context.Context, c *gomiabdns.Client) error {
+			return c.ForEachHost(ctx, "", "", func(gomiabdns.DNSRecord) error { return nil })
+		}},
+		{"AddHost", func(ctx context.Context, c *gomiabdns.Client) error {
+			_, err := c.AddHost(ctx, "www.example.com", gomiabdns.A, "1.2.3.4")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The server only notices the client hanging up once the body was read.
+				_, _ = io.Copy(io.Discard, r.Body)
+				if requests.Add(1) == 1 {
+					close(started)
+				}
+				// Block until the client gives up on the request.
+				<-r.Context().Done()
+			}))
+			defer srv.Close()
+			c, err := gomiabdns.NewWithOptions(srv.URL, miabtest.Email, miabtest.Password, gomiabdns.WithRetry(3, time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				<-started
+				cancel()
+			}()
+			done := make(chan error, 1)
+			go func() { done <- tt.call(ctx, c) }()
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("%s error = %v, want context.Canceled", tt.name, err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s didn't return after its context was cancelled", tt.name)
+			}
+			if got := requests.Load(); got != 1 {
+				t.Errorf("got %d requests, want the cancelled one not retried", got)
+			}
+		})
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	const limit = 1024
+	tests := []struct {
+		name    string
+		size    int
+		wantErr error
+	}{
+		{name: "at the limit", size: limit},
+		{name: "over the limit", size: limit + 1, wantErr: gomiabdns.ErrResponseTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(strings.Repeat("x", tt.size)))
+			}))
+			defer srv.Close()
+			c, err := gomiabdns.NewWithOptions(srv.URL, "admin@example.com", "password", gomiabdns.WithMaxResponseSize(limit))
+			if err != nil {
+				t.Fatal(err)
+			}
+			zonefile, err := c.GetZonefile(context.Background(), "example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetZonefile error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && len(zonefile) != tt.size {
+				t.Errorf("GetZonefile returned %d bytes, want %d", len(zonefile), tt.size)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	s := miabtest.NewServer("example.com")
+	defer s.Close()
+	c, err := gomiabdns.New(s.APIURL(), miabtest.Email, miabtest.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetHosts(context.Background(), "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	requests := s.RequestsTo(http.MethodGet, "/admin/dns/custom")
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(requests))
+	}
+	first, second := requests[0].Header.Get("X-Request-ID"), requests[1].Header.Get("X-Request-ID")
+	if len(first) != 36 || strings.Count(first, "-") != 4 {
+		t.Errorf("X-Request-ID = %q, want a UUID", first)
+	}
+	if first == second {
+		t.Errorf("two requests were sent with the same X-Request-ID %q", first)
+	}
+}
+
+func TestRequestIDRetried(t *testing.T) {
+	var mu sync.Mutex
+	var ids []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+		mu.Unlock()
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c, err := gomiabdns.NewWithOptions(srv.URL, "admin@example.com", "password", gomiabdns.WithRetry(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetHosts(context.Background(), "", "")
+	var apiErr *gomiabdns.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("GetHosts error = %v, want an APIError", err)
+	}
+	if len(ids) != 3 || ids[0] == "" || ids[1] != ids[0] || ids[2] != ids[0] {
+		t.Errorf("the attempts were sent with the ids %q, want one id for all 3", ids)
+	}
+	if len(ids) > 0 && apiErr.RequestID != ids[0] {
+		t.Errorf("APIError.RequestID = %q, want %q", apiErr.RequestID, ids[0])
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/luv2code/gomiabdns"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+var shells = []string{"bash", "zsh", "fish"}
+
+// recordTypes are offered when completing -rtype.
+var recordTypes = []gomiabdns.RecordType{
+	gomiabdns.A,
+	gomiabdns.AAAA,
+	gomiabdns.CAA,
+	gomiabdns.CNAME,
+	gomiabdns.MX,
+	gomiabdns.NS,
+	gomiabdns.TXT,
+	gomiabdns.SRV,
+	gomiabdns.SSHFP,
+}
+
+// flagValues are the values completed for flags that take one of a fixed set.
+func flagValues() map[string][]string {
+	types := make([]string, len(recordTypes))
+	for i, t := range recordTypes {
+		types[i] = string(t)
+	}
+	return map[string][]string{
+		"command": commands,
+		"rtype":   types,
+		"output":  outputs,
+		"shell":   shells,
+	}
+}
+
+// writeCompletion writes a completion script for shell to w.
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+		writeBashCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	default:
+		return newUsageError("The shell argument must be a valid shell: %s", strings.Join(shells, ","))
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintln(w, "_miabdns() {")
+	fmt.Fprintln(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"")
+	fmt.Fprintln(w, "\tcase \"$prev\" in")
+	values := flagValues()
+	names := maps.Keys(values)
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "\t-%s|--%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", name, name, strings.Join(values[name], " "))
+	}
+	fmt.Fprintln(w, "\t-file|--file) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;")
+	fmt.Fprintln(w, "\tesac")
+	var flags []string
+	flag.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, "-"+f.Name)
+	})
+	fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(flags, " "))
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _miabdns miabdns")
+}
+
+func writeFishCompletion(w io.Writer) {
+	values := flagValues()
+	flag.VisitAll(func(f *flag.Flag) {
+		line := "complete -c miabdns -o " + f.Name + " -d " + fishQuote(f.Usage)
+		if v, ok := values[f.Name]; ok {
+			line += " -x -a " + fishQuote(strings.Join(v, " "))
+		} else if f.Name == "file" {
+			line += " -r -F"
+		} else if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+			line += " -x"
+		}
+		fmt.Fprintln(w, line)
+	})
+}
+
+// fishQuote single quotes s for fish, which expands variables in double quotes.
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
@@ -2,13 +2,23 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"text/tabwriter"
+	"time"
 
 	"github.com/luv2code/gomiabdns"
+	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
 
@@ -19,91 +29,626 @@ var command string
 var recordType string
 var recordName string
 var recordValue string
+var output string
+var file string
+var continueOnError bool
+var dryRun bool
+var timeout time.Duration
+var interval time.Duration
+var prune bool
+var allNames bool
+var shell string
+var showVersion bool
+var totp string
+var totpCode string
+var deleteAll bool
+var assumeYes bool
+var showCounts bool
 
-var commands = []string{"list", "add", "update", "delete"}
+var commands = []string{"list", "zones", "add", "update", "delete", "import", "delete-batch", "export", "diff", "sync", "watch", "completion", "version"}
+var outputs = []string{"table", "json", "jsonl", "csv"}
 
 func init() {
 	flag.StringVar(&command, "command", "list", "the command to perform: "+strings.Join(commands, ","))
-	flag.StringVar(&email, "email", "", "The email address of the admin user")
-	flag.StringVar(&url, "url", "", "The url of the endpoint for dns changes on your Mail-In-A-Box instance. Ex: https://box.mydomain.net/admin/dns/custom")
-	flag.StringVar(&password, "password", "", "The password of the admin user")
+	flag.StringVar(&email, "email", "", "The email address of the admin user. Defaults to $MIAB_EMAIL")
+	flag.StringVar(&url, "url", "", "The url of the endpoint for dns changes on your Mail-In-A-Box instance. Ex: https://box.mydomain.net/admin/dns/custom, the /admin/dns/custom part may be left out. Defaults to $MIAB_URL")
+	flag.StringVar(&password, "password", "", "The password of the admin user. Defaults to $MIAB_PASSWORD")
+	flag.StringVar(&totp, "totp", "", "The base32 secret or otpauth:// URI of the admin user's two-factor authentication, if enabled. Defaults to $MIAB_TOTP")
+	flag.StringVar(&totpCode, "totp-code", "", "A current code from the admin user's authenticator app, used instead of -totp")
 	flag.StringVar(&recordType, "rtype", "", "The record type to act on (optional) defaults to 'A' ")
 	flag.StringVar(&recordName, "rname", "", "The record name to act on")
 	flag.StringVar(&recordValue, "rvalue", "", "The record value to act on")
-	flag.Parse()
+	flag.StringVar(&file, "file", "", "The file to read records from for the import, delete-batch, diff and sync commands, JSON or CSV (name,type,value). Use - for stdin")
+	flag.BoolVar(&continueOnError, "continue-on-error", false, "Keep importing or deleting the remaining records after one fails")
+	flag.BoolVar(&dryRun, "dry-run", false, "Show the changes add, update, delete, import, delete-batch and sync would make without making them")
+	flag.BoolVar(&deleteAll, "all", false, "Let delete remove every record of -rname and -rtype instead of the one with -rvalue")
+	flag.BoolVar(&assumeYes, "yes", false, "Delete without asking for confirmation. Required for delete, delete-batch and sync -prune when not running in a terminal")
+	flag.BoolVar(&assumeYes, "y", false, "Shorthand for -yes")
+	flag.BoolVar(&allNames, "allnames", false, "List the records of -rtype for every name, for ex. every MX record on the box")
+	flag.BoolVar(&showCounts, "counts", false, "Let zones show how many custom records each zone has")
+	flag.BoolVar(&prune, "prune", false, "Let sync delete, or replace, records of the names in the file that the file doesn't list")
+	flag.StringVar(&shell, "shell", "bash", "The shell the completion command writes a script for: "+strings.Join(shells, ","))
+	flag.BoolVar(&showVersion, "version", false, "Print the version of miabdns and exit")
+	flag.DurationVar(&timeout, "timeout", 30*time.Second, "How long the command, or each poll of watch, may run before it is abandoned. 0 means no limit")
+	flag.DurationVar(&interval, "interval", 10*time.Second, "How often watch polls the box for changed records")
+	flag.StringVar(&output, "output", "table", "The format of listed records: "+strings.Join(outputs, ",")+". export writes json unless csv or jsonl is chosen")
+	// The library sends its Version in the User-Agent header.
+	gomiabdns.Version, _, _ = buildVersion()
+}
+
+// envDefault sets value from the environment variable key when the flag was left empty,
+// so flags always take precedence over the environment.
+func envDefault(value *string, key string) {
+	if *value == "" {
+		*value = os.Getenv(key)
+	}
+}
+
+// Exit codes. Usage errors are bad flags or arguments, failures are everything else:
+// authentication, network and errors reported by the box.
+const (
+	exitFailure   = 1
+	exitUsage     = 2
+	exitCancelled = 130
+)
+
+// errCancelled is returned when the command is stopped by SIGINT or SIGTERM.
+var errCancelled = errors.New("cancelled")
+
+// usageError marks an error caused by how the command was invoked.
+type usageError struct {
+	error
+}
+
+func newUsageError(format string, a ...any) error {
+	return usageError{fmt.Errorf(format, a...)}
 }
+
 func main() {
+	flag.Parse()
+	envDefault(&email, "MIAB_EMAIL")
+	envDefault(&password, "MIAB_PASSWORD")
+	envDefault(&url, "MIAB_URL")
+	envDefault(&totp, "MIAB_TOTP")
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			os.Exit(exitUsage)
+		}
+		if errors.Is(err, errCancelled) {
+			os.Exit(exitCancelled)
+		}
+		os.Exit(exitFailure)
+	}
+}
+
+func run() error {
+	if showVersion {
+		writeVersion(os.Stdout)
+		return nil
+	}
 	if command == "" {
 		command = "list"
 	}
 	if !slices.Contains(commands, command) {
-		fmt.Println("The command argument must be a valid command: " + strings.Join(commands, ","))
-		return
+		return newUsageError("The command argument must be a valid command: %s", strings.Join(commands, ","))
+	}
+	if !slices.Contains(outputs, output) {
+		return newUsageError("The output argument must be a valid format: %s", strings.Join(outputs, ","))
 	}
-	c := gomiabdns.New(url, email, password)
+	switch command {
+	case "completion":
+		return writeCompletion(os.Stdout, shell)
+	case "version":
+		writeVersion(os.Stdout)
+		return nil
+	}
+	var opts []gomiabdns.Option
+	if dryRun {
+		// The library logs the requests a dry run skips at the info level, and every request at debug.
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		opts = append(opts, gomiabdns.WithDryRun(), gomiabdns.WithLogger(logger))
+	}
+	if strings.HasPrefix(totp, "otpauth:") {
+		opts = append(opts, gomiabdns.WithTOTPURI(totp))
+	} else if totp != "" {
+		opts = append(opts, gomiabdns.WithTOTPSecret(totp))
+	}
+	if totpCode != "" {
+		opts = append(opts, gomiabdns.WithTOTPCode(totpCode))
+	}
+	c, err := gomiabdns.NewWithOptions(url, email, password, opts...)
+	if err != nil {
+		return usageError{err}
+	}
+	// Ctrl-C cancels the requests in flight and stops the command. A second one kills it.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	err = runWithTimeout(ctx, c)
+	if errors.Is(err, gomiabdns.ErrTOTPRequired) && interactive() {
+		// Nothing was changed, the box refuses every request without a code.
+		code, promptErr := promptTOTPCode(ctx)
+		if errors.Is(promptErr, io.EOF) {
+			// Nobody is there to answer, for ex. stdin is /dev/null.
+			return err
+		}
+		if promptErr != nil {
+			err = promptErr
+		} else {
+			c.TOTPCode = code
+			err = runWithTimeout(ctx, c)
+		}
+	}
+	if errors.Is(err, context.Canceled) {
+		return errCancelled
+	}
+	return err
+}
+
+// runWithTimeout runs the command, abandoning it once the -timeout has passed. watch runs until
+// interrupted and applies the timeout to each poll instead.
+func runWithTimeout(ctx context.Context, c *gomiabdns.Client) error {
+	if timeout > 0 && command != "watch" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := runCommand(ctx, c)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("request timed out after %s", timeout)
+	}
+	return err
+}
+
+func runCommand(ctx context.Context, c *gomiabdns.Client) error {
 	switch command {
 	case "list":
-		records, err := getRecords(c)
+		if output == "jsonl" && !allNames {
+			return streamRecords(ctx, c, recordName, gomiabdns.RecordType(recordType))
+		}
+		records, err := getRecords(ctx, c)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		printRecords(records)
+		return writeRecords(records)
+	case "zones":
+		return listZones(ctx, c)
 	case "add":
-		if err := addRecord(c); err != nil {
-			panic(err)
+		result, err := addRecord(ctx, c)
+		if err != nil {
+			return err
 		}
-		fmt.Println("record added")
+		printResult("added", result)
 	case "update":
-		if err := updateRecord(c); err != nil {
-			panic(err)
+		result, err := updateRecord(ctx, c)
+		if err != nil {
+			return err
 		}
-		fmt.Println("record updated")
+		printResult("updated", result)
 	case "delete":
-		if err := deleteRecord(c); err != nil {
-			panic(err)
+		result, err := deleteRecord(ctx, c)
+		if err != nil {
+			return err
 		}
-		fmt.Println("record deleted")
+		printResult("deleted", result)
+	case "import":
+		return importRecords(ctx, c)
+	case "delete-batch":
+		return deleteBatch(ctx, c)
+	case "export":
+		return exportRecords(ctx, c)
+	case "diff":
+		return diffRecords(ctx, c)
+	case "sync":
+		return syncRecords(ctx, c)
+	case "watch":
+		return watchRecords(ctx, c)
 	}
+	return nil
 }
 
-func getRecords(c *gomiabdns.Client) ([]gomiabdns.DNSRecord, error) {
-	records, err := c.GetHosts(context.TODO(), recordName, gomiabdns.RecordType(recordType))
+func getRecords(ctx context.Context, c *gomiabdns.Client) ([]gomiabdns.DNSRecord, error) {
+	if allNames {
+		return getRecordsOfType(ctx, c)
+	}
+	records, err := c.GetHosts(ctx, recordName, gomiabdns.RecordType(recordType))
 	if err != nil {
 		return nil, err
 	}
 	return records, nil
 }
 
-func addRecord(c *gomiabdns.Client) error {
+// getRecordsOfType returns the records of every name with the requested type. The API can't
+// filter by type alone, so all records are fetched and filtered here.
+func getRecordsOfType(ctx context.Context, c *gomiabdns.Client) ([]gomiabdns.DNSRecord, error) {
+	if recordType == "" || recordName != "" {
+		return nil, newUsageError("The allnames argument requires rtype and can't be combined with rname.")
+	}
+	records, err := c.GetAllRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result []gomiabdns.DNSRecord
+	for _, record := range records {
+		if strings.EqualFold(string(record.RecordType), recordType) {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+func addRecord(ctx context.Context, c *gomiabdns.Client) (gomiabdns.MutationResult, error) {
+	if recordName == "" || recordType == "" || recordValue == "" {
+		return gomiabdns.MutationResult{}, newUsageError("Missing parameters to add command. all are required. rname: %s, rtype: %s, rvalue: %s ", recordName, recordType, recordValue)
+	}
+	return c.AddHost(ctx, recordName, gomiabdns.RecordType(recordType), recordValue)
+}
+
+func updateRecord(ctx context.Context, c *gomiabdns.Client) (gomiabdns.MutationResult, error) {
 	if recordName == "" || recordType == "" || recordValue == "" {
-		return fmt.Errorf("Missing parameters to add command. all are required. rname: %s, rtype: %s, rvalue: %s ", recordName, recordType, recordValue)
+		return gomiabdns.MutationResult{}, newUsageError("Missing parameters to update command. all are required. rname: %s, rtype: %s, rvalue: %s ", recordName, recordType, recordValue)
+	}
+	return c.UpdateHost(ctx, recordName, gomiabdns.RecordType(recordType), recordValue)
+}
+
+// login logs in ahead of a command that asks for confirmation or makes several changes, so that
+// when the box wants a two-factor authentication code, it is asked for before the confirmation
+// and before any record is changed, rather than failing every record of a batch.
+func login(ctx context.Context, c *gomiabdns.Client) error {
+	if dryRun || c.APIKey() != "" {
+		return nil
+	}
+	return c.Login(ctx)
+}
+
+// deleteRecord deletes the record with the given value, or with -all, every record of the name and type.
+func deleteRecord(ctx context.Context, c *gomiabdns.Client) (gomiabdns.MutationResult, error) {
+	if recordName == "" || recordType == "" {
+		return gomiabdns.MutationResult{}, newUsageError("Missing parameters to delete command. rname and rtype are required.")
+	}
+	if deleteAll && recordValue != "" {
+		return gomiabdns.MutationResult{}, newUsageError("The all argument deletes every value and can't be combined with rvalue.")
+	}
+	if !deleteAll && recordValue == "" {
+		return gomiabdns.MutationResult{}, newUsageError("Missing parameters to delete command. rvalue is required, or all to delete every value.")
+	}
+	if err := login(ctx, c); err != nil {
+		return gomiabdns.MutationResult{}, err
+	}
+	if deleteAll {
+		if !dryRun {
+			if err := confirm(ctx, fmt.Sprintf("Delete every %s record of %s?", recordType, recordName)); err != nil {
+				return gomiabdns.MutationResult{}, err
+			}
+		}
+		return c.DeleteHost(ctx, recordName, gomiabdns.RecordType(recordType), "")
+	}
+	if !dryRun {
+		if err := confirm(ctx, fmt.Sprintf("Delete %s %s %s?", recordName, recordType, recordValue)); err != nil {
+			return gomiabdns.MutationResult{}, err
+		}
+	}
+	return c.DeleteExact(ctx, recordName, gomiabdns.RecordType(recordType), recordValue)
+}
+
+// printResult prints the box's own description of what a mutation did, so an add or delete the
+// box had nothing to do for doesn't look like it changed anything.
+func printResult(verb string, result gomiabdns.MutationResult) {
+	switch {
+	case dryRun:
+		fmt.Println("record " + changed(verb))
+	case result.Changed:
+		fmt.Println(result.Message)
+	case result.Message == "":
+		fmt.Printf("record not %s, nothing changed\n", verb)
+	default:
+		fmt.Printf("record not %s, nothing changed: %s\n", verb, result.Message)
+	}
+}
+
+func writeRecords(records []gomiabdns.DNSRecord) error {
+	switch output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case "jsonl":
+		encoder := json.NewEncoder(os.Stdout)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		return writeRecordsCSV(os.Stdout, records)
+	}
+	printRecords(records)
+	return nil
+}
+
+// streamRecords writes the records GetHosts would return as JSON lines, each as soon as it is
+// read from the response, so a consumer can start on them before the whole list has arrived.
+func streamRecords(ctx context.Context, c *gomiabdns.Client, name string, recordType gomiabdns.RecordType) error {
+	encoder := json.NewEncoder(os.Stdout)
+	return c.ForEachHost(ctx, name, recordType, func(record gomiabdns.DNSRecord) error {
+		return encoder.Encode(record)
+	})
+}
+
+// exportRecords writes every custom record on the box in a format the import command reads back.
+func exportRecords(ctx context.Context, c *gomiabdns.Client) error {
+	if output == "jsonl" {
+		return streamRecords(ctx, c, "", "")
+	}
+	records, err := c.GetHosts(ctx, "", "")
+	if err != nil {
+		return err
+	}
+	if output == "table" {
+		output = "json"
+	}
+	return writeRecords(records)
+}
+
+// listZones writes the zones the box serves, with -counts along with their number of custom records.
+func listZones(ctx context.Context, c *gomiabdns.Client) error {
+	if !showCounts {
+		zones, err := c.GetZones(ctx)
+		if err != nil {
+			return err
+		}
+		slices.Sort(zones)
+		switch output {
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(zones)
+		case "jsonl":
+			encoder := json.NewEncoder(os.Stdout)
+			for _, zone := range zones {
+				if err := encoder.Encode(zone); err != nil {
+					return err
+				}
+			}
+			return nil
+		case "csv":
+			writer := csv.NewWriter(os.Stdout)
+			writer.Write([]string{"zone"})
+			for _, zone := range zones {
+				writer.Write([]string{zone})
+			}
+			writer.Flush()
+			return writer.Error()
+		}
+		for _, zone := range zones {
+			fmt.Println(zone)
+		}
+		return nil
 	}
-	if err := c.AddHost(context.TODO(), recordName, gomiabdns.RecordType(recordType), recordValue); err != nil {
+	counts, err := c.GetZonesWithCounts(ctx)
+	if err != nil {
 		return err
 	}
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(counts)
+	}
+	zones := maps.Keys(counts)
+	slices.Sort(zones)
+	if output == "jsonl" {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, zone := range zones {
+			line := struct {
+				Zone    string `json:"zone"`
+				Records int    `json:"records"`
+			}{zone, counts[zone]}
+			if err := encoder.Encode(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if output == "csv" {
+		writer := csv.NewWriter(os.Stdout)
+		writer.Write([]string{"zone", "records"})
+		for _, zone := range zones {
+			writer.Write([]string{zone, strconv.Itoa(counts[zone])})
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', tabwriter.Debug)
+	fmt.Fprintln(writer, "Zone\t Records")
+	for _, zone := range zones {
+		fmt.Fprintf(writer, "%s\t %d\n", zone, counts[zone])
+	}
+	return writer.Flush()
+}
+
+// changed describes a change that was made, or in dry run mode, that would have been.
+func changed(verb string) string {
+	if dryRun {
+		return "not " + verb + " (dry run)"
+	}
+	return verb
+}
+
+func importRecords(ctx context.Context, c *gomiabdns.Client) error {
+	if file == "" {
+		return newUsageError("Missing parameters to import command. file is required.")
+	}
+	records, err := readRecordsFile(file)
+	if err != nil {
+		return err
+	}
+	if err := login(ctx, c); err != nil {
+		return err
+	}
+	failed := 0
+	for i, record := range records {
+		_, err := c.AddHost(ctx, record.QualifiedName, record.RecordType, record.Value)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return fmt.Errorf("%w, import stopped after %d of %d records", errCancelled, i, len(records))
+			}
+			failed++
+			fmt.Printf("record %d: %s failed: %s\n", i+1, record, err)
+			if !continueOnError {
+				return fmt.Errorf("import stopped after %d of %d records", i+1, len(records))
+			}
+			continue
+		}
+		fmt.Printf("record %d: %s %s\n", i+1, record, changed("added"))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d records failed to import", failed, len(records))
+	}
 	return nil
 }
 
-func updateRecord(c *gomiabdns.Client) error {
-	if recordName == "" || recordType == "" || recordValue == "" {
-		return fmt.Errorf("Missing parameters to update command. all are required. rname: %s, rtype: %s, rvalue: %s ", recordName, recordType, recordValue)
+// deleteBatch deletes each of the records listed in file, the reverse of import.
+func deleteBatch(ctx context.Context, c *gomiabdns.Client) error {
+	if file == "" {
+		return newUsageError("Missing parameters to delete-batch command. file is required.")
 	}
-	if err := c.UpdateHost(context.TODO(), recordName, gomiabdns.RecordType(recordType), recordValue); err != nil {
+	records, err := readRecordsFile(file)
+	if err != nil {
+		return err
+	}
+	if err := login(ctx, c); err != nil {
 		return err
 	}
+	if !dryRun && len(records) > 0 {
+		if err := confirm(ctx, fmt.Sprintf("Delete the %d records in %s?", len(records), file)); err != nil {
+			return err
+		}
+	}
+	failed := 0
+	for i, record := range records {
+		result, err := c.DeleteExact(ctx, record.QualifiedName, record.RecordType, record.Value)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return fmt.Errorf("%w, delete-batch stopped after %d of %d records", errCancelled, i, len(records))
+			}
+			failed++
+			fmt.Printf("record %d: %s failed: %s\n", i+1, record, err)
+			if !continueOnError {
+				return fmt.Errorf("delete-batch stopped after %d of %d records", i+1, len(records))
+			}
+			continue
+		}
+		if !dryRun && !result.Changed {
+			fmt.Printf("record %d: %s not found, nothing changed\n", i+1, record)
+			continue
+		}
+		fmt.Printf("record %d: %s %s\n", i+1, record, changed("deleted"))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d records failed to delete", failed, len(records))
+	}
 	return nil
 }
 
-func deleteRecord(c *gomiabdns.Client) error {
-	if recordName == "" || recordType == "" {
-		return fmt.Errorf("Missing parameters to delete command. rname and rtype are required.")
+// diffRecords prints the changes that would make the records of the names in file match it.
+func diffRecords(ctx context.Context, c *gomiabdns.Client) error {
+	if file == "" {
+		return newUsageError("Missing parameters to diff command. file is required.")
 	}
-	if err := c.DeleteHost(context.TODO(), recordName, gomiabdns.RecordType(recordType), recordValue); err != nil {
+	desired, err := readRecordsFile(file)
+	if err != nil {
+		return err
+	}
+	current, err := c.GetAllRecords(ctx)
+	if err != nil {
 		return err
 	}
+	return writePlan(os.Stdout, plan(current, desired, true))
+}
+
+// syncRecords makes the records of the names in file match it. Records are only deleted or
+// replaced with -prune.
+func syncRecords(ctx context.Context, c *gomiabdns.Client) error {
+	if file == "" {
+		return newUsageError("Missing parameters to sync command. file is required.")
+	}
+	desired, err := readRecordsFile(file)
+	if err != nil {
+		return err
+	}
+	if err := login(ctx, c); err != nil {
+		return err
+	}
+	current, err := c.GetAllRecords(ctx)
+	if err != nil {
+		return err
+	}
+	changes := plan(current, desired, prune)
+	if dryRun && (output == "json" || output == "jsonl") {
+		return writePlan(os.Stdout, changes)
+	}
+	if dryRun {
+		return writeDiff(os.Stdout, current, changes)
+	}
+	// Updates delete the values they replace, so they are confirmed along with deletes.
+	var deletes []change
+	removed := 0
+	for _, ch := range changes {
+		if ch.Action == actionDelete || (ch.Action == actionUpdate && len(ch.OldValues) > 0) {
+			deletes = append(deletes, ch)
+			removed += max(len(ch.OldValues), 1)
+		}
+	}
+	if len(deletes) > 0 && !assumeYes {
+		if err := writePlan(os.Stderr, deletes); err != nil {
+			return err
+		}
+		if err := confirm(ctx, fmt.Sprintf("Delete or replace the %d records above?", removed)); err != nil {
+			return err
+		}
+	}
+	counts := map[string]int{}
+	failed := 0
+	for i, ch := range changes {
+		if err := applyChange(ctx, c, ch); err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return fmt.Errorf("%w, sync stopped after %d of %d changes", errCancelled, i, len(changes))
+			}
+			failed++
+			fmt.Printf("%s %s failed: %s\n", ch.Action, ch.record(), err)
+			if !continueOnError {
+				return fmt.Errorf("sync stopped after %d of %d changes", i+1, len(changes))
+			}
+			continue
+		}
+		counts[ch.Action]++
+	}
+	fmt.Printf("%d added, %d updated, %d deleted, %d failed\n", counts[actionAdd], counts[actionUpdate], counts[actionDelete], failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d changes failed", failed, len(changes))
+	}
 	return nil
 }
 
+func applyChange(ctx context.Context, c *gomiabdns.Client, ch change) error {
+	var err error
+	switch ch.Action {
+	case actionAdd:
+		_, err = c.AddHost(ctx, ch.QualifiedName, ch.RecordType, ch.Value)
+	case actionUpdate:
+		_, err = c.UpdateHost(ctx, ch.QualifiedName, ch.RecordType, ch.Value)
+	case actionDelete:
+		_, err = c.DeleteExact(ctx, ch.QualifiedName, ch.RecordType, ch.Value)
+	}
+	return err
+}
+
 func printRecords(records []gomiabdns.DNSRecord) {
 	writer := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', tabwriter.Debug)
 	fmt.Fprintln(writer, "Name\t Type\t Value")
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luv2code/gomiabdns"
+	"github.com/luv2code/gomiabdns/miabtest"
+)
+
+// withFlags sets the flags of a command line for the duration of the test.
+func withFlags(t *testing.T, set func()) {
+	stringFlags := []*string{&command, &url, &email, &password, &file, &totp, &totpCode, &output, &recordName, &recordType, &recordValue}
+	boolFlags := []*bool{&assumeYes, &dryRun, &prune, &deleteAll, &showCounts}
+	savedStrings, savedBools := make([]string, len(stringFlags)), make([]bool, len(boolFlags))
+	for i, p := range stringFlags {
+		savedStrings[i] = *p
+	}
+	for i, p := range boolFlags {
+		savedBools[i] = *p
+	}
+	t.Cleanup(func() {
+		for i, p := range stringFlags {
+			*p = savedStrings[i]
+		}
+		for i, p := range boolFlags {
+			*p = savedBools[i]
+		}
+	})
+	set()
+}
+
+// withTerminal makes prompts read input, as if a person typed it on a terminal.
+func withTerminal(t *testing.T, input string) {
+	savedStdin, savedInteractive := stdin, interactive
+	t.Cleanup(func() { stdin, interactive = savedStdin, savedInteractive })
+	stdin = bufio.NewReader(strings.NewReader(input))
+	interactive = func() bool { return true }
+}
+
+// writeRecordsFile writes records as JSON to a temporary file and returns its path.
+func writeRecordsFile(t *testing.T, records string) string {
+	path := filepath.Join(t.TempDir(), "records.json")
+	if err := os.WriteFile(path, []byte(records), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// captureStdout returns what run writes to standard output.
+func captureStdout(t *testing.T, run func() error) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = w
+	runErr := run()
+	os.Stdout = saved
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runErr != nil {
+		t.Fatalf("run: %v", runErr)
+	}
+	return string(out)
+}
+
+func TestListZonesCSV(t *testing.T) {
+	tests := []struct {
+		counts bool
+		want   string
+	}{
+		{want: "zone\nexample.com\nexample.org\n"},
+		{counts: true, want: "zone,records\nexample.com,1\nexample.org,0\n"},
+	}
+	for _, tt := range tests {
+		s := miabtest.NewServer("example.org", "example.com")
+		defer s.Close()
+		s.SetRecords([]gomiabdns.DNSRecord{{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.4"}})
+		withFlags(t, func() {
+			command, url, email, password = "zones", s.APIURL(), miabtest.Email, miabtest.Password
+			output, showCounts = "csv", tt.counts
+		})
+		if got := captureStdout(t, run); got != tt.want {
+			t.Errorf("zones -output csv with -counts=%v wrote\n%s\nwant\n%s", tt.counts, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPPrompt(t *testing.T) {
+	records := []gomiabdns.DNSRecord{
+		{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.4"},
+		{QualifiedName: "mail.example.com", RecordType: gomiabdns.A, Value: "1.2.3.5"},
+	}
+	recordsFile := `[{"qname": "www.example.com", "rtype": "A", "value": "1.2.3.4"}, {"qname": "mail.example.com", "rtype": "A", "value": "1.2.3.5"}]`
+	tests := []struct {
+		command string
+		// confirmed makes the command ask for a confirmation after the code.
+		confirmed   bool
+		before      []gomiabdns.DNSRecord
+		wantRecords int
+	}{
+		{command: "import", wantRecords: 2},
+		{command: "delete", confirmed: true, before: records, wantRecords: 1},
+		{command: "delete-batch", confirmed: true, before: records, wantRecords: 0},
+		{command: "sync", wantRecords: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			s := miabtest.NewServer("example.com")
+			defer s.Close()
+			s.SetTOTPSecret("GEZDGNBVGY3TQOJQ")
+			s.SetRecords(tt.before)
+			input := s.TOTPCode(time.Now()) + "\n"
+			if tt.confirmed {
+				input += "y\n"
+			}
+			withTerminal(t, input)
+			withFlags(t, func() {
+				command, url, email, password = tt.command, s.APIURL(), miabtest.Email, miabtest.Password
+				file = writeRecordsFile(t, recordsFile)
+				recordName, recordType, recordValue = "www.example.com", "A", "1.2.3.4"
+			})
+
+			if err := run(); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if got := len(s.Records()); got != tt.wantRecords {
+				t.Errorf("the box has %d records, want %d", got, tt.wantRecords)
+			}
+			// The first login finds out a code is needed, the second sends it, and the records
+			// are only changed after that.
+			if got := len(s.RequestsTo(http.MethodPost, "/admin/login")); got != 2 {
+				t.Errorf("got %d logins, want 2", got)
+			}
+			if rest, _ := stdin.ReadString('\n'); rest != "" {
+				t.Errorf("the command left %q unread, want every answer used once", rest)
+			}
+		})
+	}
+}
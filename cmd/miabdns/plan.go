@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/luv2code/gomiabdns"
+	"golang.org/x/exp/slices"
+)
+
+// Change actions.
+const (
+	actionAdd    = "add"
+	actionDelete = "delete"
+	actionUpdate = "update"
+)
+
+// change is one step needed to bring the box in line with a desired set of records.
+type change struct {
+	Action        string               `json:"action"`
+	QualifiedName string               `json:"qname"`
+	RecordType    gomiabdns.RecordType `json:"rtype"`
+	Value         string               `json:"value"`
+	// OldValues holds the values an update replaces.
+	OldValues []string `json:"old_values,omitempty"`
+}
+
+func (c change) record() gomiabdns.DNSRecord {
+	return gomiabdns.DNSRecord{QualifiedName: c.QualifiedName, RecordType: c.RecordType, Value: c.Value}
+}
+
+type recordKey struct {
+	name  string
+	rtype gomiabdns.RecordType
+}
+
+// plan compares the current records with the desired ones and returns the changes that would
+// make them match. Only names that appear in desired are considered, so records of other names
+// are never touched. Without prune, records are only added: extra values are kept. With prune,
+// they are deleted, and a name and type whose desired state is a single value that isn't present
+// yet becomes an update, which the box applies by replacing all existing values at once. Names
+// are compared normalized, as gomiabdns.NormalizeName does, and values as gomiabdns.SameValue
+// does.
+func plan(current, desired []gomiabdns.DNSRecord, prune bool) []change {
+	names := map[string]bool{}
+	want := map[recordKey][]string{}
+	for _, r := range desired {
+		name := gomiabdns.NormalizeName(r.QualifiedName)
+		names[name] = true
+		key := recordKey{name, r.RecordType}
+		if !slices.ContainsFunc(want[key], sameValue(r.RecordType, r.Value)) {
+			want[key] = append(want[key], r.Value)
+		}
+	}
+	have := map[recordKey][]string{}
+	for _, r := range current {
+		if name := gomiabdns.NormalizeName(r.QualifiedName); names[name] {
+			key := recordKey{name, r.RecordType}
+			have[key] = append(have[key], r.Value)
+		}
+	}
+	keys := make([]recordKey, 0, len(want)+len(have))
+	for key := range want {
+		keys = append(keys, key)
+	}
+	for key := range have {
+		if _, ok := want[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b recordKey) int {
+		if a.name != b.name {
+			return strings.Compare(a.name, b.name)
+		}
+		return strings.Compare(string(a.rtype), string(b.rtype))
+	})
+
+	var changes []change
+	for _, key := range keys {
+		toAdd := missing(key.rtype, want[key], have[key])
+		var toDelete []string
+		if prune {
+			toDelete = missing(key.rtype, have[key], want[key])
+		}
+		if len(want[key]) == 1 && len(toAdd) == 1 && len(toDelete) > 0 {
+			changes = append(changes, change{Action: actionUpdate, QualifiedName: key.name, RecordType: key.rtype, Value: toAdd[0], OldValues: toDelete})
+			continue
+		}
+		for _, value := range toDelete {
+			changes = append(changes, change{Action: actionDelete, QualifiedName: key.name, RecordType: key.rtype, Value: value})
+		}
+		for _, value := range toAdd {
+			changes = append(changes, change{Action: actionAdd, QualifiedName: key.name, RecordType: key.rtype, Value: value})
+		}
+	}
+	return changes
+}
+
+// missing returns the values of a that aren't in b, for records of recordType.
+func missing(recordType gomiabdns.RecordType, a, b []string) []string {
+	var result []string
+	for _, value := range a {
+		if !slices.ContainsFunc(b, sameValue(recordType, value)) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// sameValue returns a function reporting whether a value of a recordType record is the same as value.
+func sameValue(recordType gomiabdns.RecordType, value string) func(string) bool {
+	return func(other string) bool { return gomiabdns.SameValue(recordType, value, other) }
+}
+
+// writePlan prints changes as JSON when -output json is set, as JSON lines with -output jsonl,
+// or one per line otherwise, prefixed + for additions, - for deletions and ~ for updates.
+func writePlan(w io.Writer, changes []change) error {
+	if output == "jsonl" {
+		encoder := json.NewEncoder(w)
+		for _, c := range changes {
+			if err := encoder.Encode(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if output == "json" {
+		if changes == nil {
+			changes = []change{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(changes)
+	}
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "no changes")
+		return err
+	}
+	for _, c := range changes {
+		var err error
+		switch c.Action {
+		case actionAdd:
+			_, err = fmt.Fprintf(w, "+ %s\n", c.record())
+		case actionDelete:
+			_, err = fmt.Fprintf(w, "- %s\n", c.record())
+		case actionUpdate:
+			_, err = fmt.Fprintf(w, "~ %s %s %s -> %s\n", c.QualifiedName, c.RecordType, strings.Join(c.OldValues, ", "), c.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDiff prints changes as a unified diff from the current records of the names they touch to
+// the records after applying them, for reviewing them like code. Records that stay are context.
+func writeDiff(w io.Writer, current []gomiabdns.DNSRecord, changes []change) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "no changes")
+		return err
+	}
+	type valueKey struct {
+		recordKey
+		value string
+	}
+	var names []string
+	removed := map[valueKey]bool{}
+	added := map[string][]gomiabdns.DNSRecord{}
+	for _, c := range changes {
+		if !slices.Contains(names, c.QualifiedName) {
+			names = append(names, c.QualifiedName)
+		}
+		key := recordKey{c.QualifiedName, c.RecordType}
+		switch c.Action {
+		case actionAdd:
+			added[c.QualifiedName] = append(added[c.QualifiedName], c.record())
+		case actionDelete:
+			removed[valueKey{key, c.Value}] = true
+		case actionUpdate:
+			for _, value := range c.OldValues {
+				removed[valueKey{key, value}] = true
+			}
+			added[c.QualifiedName] = append(added[c.QualifiedName], c.record())
+		}
+	}
+	byName := map[string][]gomiabdns.DNSRecord{}
+	for _, r := range current {
+		name := gomiabdns.NormalizeName(r.QualifiedName)
+		byName[name] = append(byName[name], r)
+	}
+	if _, err := fmt.Fprint(w, "--- current\n+++ desired\n"); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "@@ %s @@\n", name); err != nil {
+			return err
+		}
+		for _, r := range byName[name] {
+			prefix := " "
+			if removed[valueKey{recordKey{name, r.RecordType}, r.Value}] {
+				prefix = "-"
+			}
+			if _, err := fmt.Fprintf(w, "%s%s\n", prefix, r); err != nil {
+				return err
+			}
+		}
+		for _, r := range added[name] {
+			if _, err := fmt.Fprintf(w, "+%s\n", r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
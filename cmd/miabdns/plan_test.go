@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/luv2code/gomiabdns"
+	"golang.org/x/exp/slices"
+)
+
+func TestPlan(t *testing.T) {
+	current := []gomiabdns.DNSRecord{
+		{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.4"},
+		{QualifiedName: "WWW.example.com.", RecordType: gomiabdns.A, Value: "1.2.3.5"},
+		{QualifiedName: "alias.example.com", RecordType: gomiabdns.CNAME, Value: "Target.Example.com."},
+		{QualifiedName: "example.com", RecordType: gomiabdns.TXT, Value: "v=spf1 mx -all"},
+		{QualifiedName: "other.example.com", RecordType: gomiabdns.A, Value: "1.2.3.9"},
+	}
+	tests := []struct {
+		name    string
+		desired []gomiabdns.DNSRecord
+		prune   bool
+		want    []change
+	}{
+		{
+			name: "in sync",
+			desired: []gomiabdns.DNSRecord{
+				{QualifiedName: "www.example.com.", RecordType: gomiabdns.A, Value: "1.2.3.4"},
+				{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.5"},
+				// CNAME targets are names, compared like the box does.
+				{QualifiedName: "alias.example.com", RecordType: gomiabdns.CNAME, Value: "target.example.com"},
+			},
+			prune: true,
+		},
+		{
+			name:    "add without prune keeps other values",
+			desired: []gomiabdns.DNSRecord{{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.6"}},
+			want:    []change{{Action: actionAdd, QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.6"}},
+		},
+		{
+			name:    "replace with prune",
+			desired: []gomiabdns.DNSRecord{{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.6"}},
+			prune:   true,
+			want:    []change{{Action: actionUpdate, QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.6", OldValues: []string{"1.2.3.4", "1.2.3.5"}}},
+		},
+		{
+			name:    "delete with prune",
+			desired: []gomiabdns.DNSRecord{{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.4"}},
+			prune:   true,
+			want:    []change{{Action: actionDelete, QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.5"}},
+		},
+		{
+			name: "delete other types of a name with prune",
+			desired: []gomiabdns.DNSRecord{
+				{QualifiedName: "example.com", RecordType: gomiabdns.MX, Value: "10 mail.example.com"},
+			},
+			prune: true,
+			want: []change{
+				{Action: actionAdd, QualifiedName: "example.com", RecordType: gomiabdns.MX, Value: "10 mail.example.com"},
+				{Action: actionDelete, QualifiedName: "example.com", RecordType: gomiabdns.TXT, Value: "v=spf1 mx -all"},
+			},
+		},
+		{
+			name:    "values of other types compared exactly",
+			desired: []gomiabdns.DNSRecord{{QualifiedName: "example.com", RecordType: gomiabdns.TXT, Value: "V=spf1 mx -all"}},
+			want:    []change{{Action: actionAdd, QualifiedName: "example.com", RecordType: gomiabdns.TXT, Value: "V=spf1 mx -all"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := plan(current, tt.desired, tt.prune)
+			if !slices.EqualFunc(got, tt.want, func(a, b change) bool {
+				return a.Action == b.Action && a.QualifiedName == b.QualifiedName && a.RecordType == b.RecordType &&
+					a.Value == b.Value && slices.Equal(a.OldValues, b.OldValues)
+			}) {
+				t.Errorf("plan returned %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// goldenCurrent and goldenDesired plan an add, a delete, a replace and a record that stays.
+var (
+	goldenCurrent = []gomiabdns.DNSRecord{
+		{QualifiedName: "example.com", RecordType: gomiabdns.TXT, Value: "v=spf1 mx -all"},
+		{QualifiedName: "example.com", RecordType: gomiabdns.MX, Value: "10 mail.example.com"},
+		{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.4"},
+		{QualifiedName: "www.example.com", RecordType: gomiabdns.AAAA, Value: "::1"},
+		{QualifiedName: "www.example.com", RecordType: gomiabdns.AAAA, Value: "::2"},
+	}
+	goldenDesired = []gomiabdns.DNSRecord{
+		{QualifiedName: "example.com", RecordType: gomiabdns.TXT, Value: "v=spf1 mx -all"},
+		{QualifiedName: "example.com", RecordType: gomiabdns.MX, Value: "10 mail.example.com"},
+		{QualifiedName: "mail.example.com", RecordType: gomiabdns.A, Value: "1.2.3.5"},
+		{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.6"},
+		{QualifiedName: "www.example.com", RecordType: gomiabdns.AAAA, Value: "::1"},
+	}
+)
+
+func TestWritePlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		desired []gomiabdns.DNSRecord
+		want    string
+	}{
+		{
+			name:    "text",
+			desired: goldenDesired,
+			want: `+ mail.example.com A 1.2.3.5
+~ www.example.com A 1.2.3.4 -> 1.2.3.6
+- www.example.com AAAA ::2
+`,
+		},
+		{name: "text no changes", desired: goldenCurrent, want: "no changes\n"},
+		{
+			name:    "jsonl",
+			output:  "jsonl",
+			desired: goldenDesired,
+			want: `{"action":"add","qname":"mail.example.com","rtype":"A","value":"1.2.3.5"}
+{"action":"update","qname":"www.example.com","rtype":"A","value":"1.2.3.6","old_values":["1.2.3.4"]}
+{"action":"delete","qname":"www.example.com","rtype":"AAAA","value":"::2"}
+`,
+		},
+		{name: "jsonl no changes", output: "jsonl", desired: goldenCurrent, want: ""},
+		{
+			name:    "json",
+			output:  "json",
+			desired: goldenDesired[2:3],
+			want: `[
+  {
+    "action": "add",
+    "qname": "mail.example.com",
+    "rtype": "A",
+    "value": "1.2.3.5"
+  }
+]
+`,
+		},
+		{name: "json no changes", output: "json", desired: goldenCurrent, want: "[]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withFlags(t, func() { output = tt.output })
+			var buf bytes.Buffer
+			if err := writePlan(&buf, plan(goldenCurrent, tt.desired, true)); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writePlan wrote\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired []gomiabdns.DNSRecord
+		want    string
+	}{
+		{
+			name:    "changes",
+			desired: goldenDesired,
+			want: `--- current
++++ desired
+@@ mail.example.com @@
++mail.example.com A 1.2.3.5
+@@ www.example.com @@
+-www.example.com A 1.2.3.4
+ www.example.com AAAA ::1
+-www.example.com AAAA ::2
++www.example.com A 1.2.3.6
+`,
+		},
+		{name: "no changes", desired: goldenCurrent, want: "no changes\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeDiff(&buf, goldenCurrent, plan(goldenCurrent, tt.desired, true)); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeDiff wrote\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// stdin is where prompts read their answers from, and interactive reports whether a person is
+// there to give them. Tests replace both.
+var (
+	stdin       = bufio.NewReader(os.Stdin)
+	interactive = func() bool { return isTerminal(os.Stdin) }
+)
+
+// isTerminal reports whether f is a terminal rather than a pipe or file, so a person can answer a prompt.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// readLine reads a line from stdin, giving up when ctx is done, for ex. because of Ctrl-C.
+func readLine(ctx context.Context) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		line, err := stdin.ReadString('\n')
+		results <- result{line, err}
+	}()
+	select {
+	case r := <-results:
+		return r.line, r.err
+	case <-ctx.Done():
+		fmt.Fprintln(os.Stderr)
+		return "", ctx.Err()
+	}
+}
+
+// promptTOTPCode asks for a code from the admin user's authenticator app on the terminal.
+func promptTOTPCode(ctx context.Context) (string, error) {
+	fmt.Fprint(os.Stderr, "Two-factor authentication code: ")
+	line, err := readLine(ctx)
+	if err != nil {
+		return "", fmt.Errorf("reading two-factor authentication code: %w", err)
+	}
+	code := strings.TrimSpace(line)
+	if code == "" {
+		return "", newUsageError("No two-factor authentication code entered.")
+	}
+	return code, nil
+}
+
+// confirm asks the user to confirm a destructive action, unless -yes was given. Without a terminal
+// to ask on, -yes is required.
+func confirm(ctx context.Context, question string) error {
+	if assumeYes {
+		return nil
+	}
+	if !interactive() {
+		return newUsageError("%s Pass -yes to confirm when not running in a terminal.", question)
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	line, err := readLine(ctx)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return nil
+	}
+	return errors.New("cancelled, nothing was changed")
+}
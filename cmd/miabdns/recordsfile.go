@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/luv2code/gomiabdns"
+)
+
+// csvHeader is the optional first row of a records CSV file.
+var csvHeader = []string{"name", "type", "value"}
+
+// readRecordsFile reads records from path, or from stdin when path is "-". The file is either
+// a JSON array of records, as written by -output json, JSON lines with one record per line, as
+// written by -output jsonl, or CSV with name, type and value columns.
+func readRecordsFile(path string) ([]gomiabdns.DNSRecord, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	br := bufio.NewReader(r)
+	switch firstByte(br) {
+	case '[':
+		var records []gomiabdns.DNSRecord
+		if err := json.NewDecoder(br).Decode(&records); err != nil {
+			return nil, fmt.Errorf("Error reading records from %s: %w", path, err)
+		}
+		return records, nil
+	case '{':
+		return readRecordsJSONLines(br, path)
+	}
+	return readRecordsCSV(br, path)
+}
+
+// firstByte peeks at the first non-space byte of r to tell a JSON array or JSON lines from CSV.
+// It returns 0 if r has none.
+func firstByte(r *bufio.Reader) byte {
+	for n := 1; ; n++ {
+		peeked, err := r.Peek(n)
+		if err != nil {
+			return 0
+		}
+		trimmed := bytes.TrimSpace(peeked)
+		if len(trimmed) > 0 {
+			return trimmed[0]
+		}
+	}
+}
+
+func readRecordsJSONLines(r io.Reader, path string) ([]gomiabdns.DNSRecord, error) {
+	var records []gomiabdns.DNSRecord
+	decoder := json.NewDecoder(r)
+	for {
+		var record gomiabdns.DNSRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("Error reading records from %s: %w", path, err)
+		}
+		records = append(records, record)
+	}
+}
+
+func readRecordsCSV(r io.Reader, path string) ([]gomiabdns.DNSRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(csvHeader)
+	reader.Comment = '#'
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading records from %s: %w", path, err)
+	}
+	if len(rows) > 0 && strings.EqualFold(strings.Join(rows[0], ","), strings.Join(csvHeader, ",")) {
+		rows = rows[1:]
+	}
+	records := make([]gomiabdns.DNSRecord, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, gomiabdns.DNSRecord{
+			QualifiedName: strings.TrimSpace(row[0]),
+			RecordType:    gomiabdns.RecordType(strings.ToUpper(strings.TrimSpace(row[1]))),
+			Value:         row[2],
+		})
+	}
+	return records, nil
+}
+
+func writeRecordsCSV(w io.Writer, records []gomiabdns.DNSRecord) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := writer.Write([]string{record.QualifiedName, string(record.RecordType), record.Value}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
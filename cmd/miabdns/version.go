@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+
+	"github.com/luv2code/gomiabdns"
+)
+
+// commit and date identify the build. Release builds set them, along with gomiabdns.Version, with
+// -ldflags "-X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)".
+// Otherwise they are taken from the version control information go build embeds, if any.
+var (
+	commit = ""
+	date   = ""
+)
+
+// buildVersion returns the version, commit and build date of the binary. A version installed with
+// go install is taken from the module when gomiabdns.Version wasn't set.
+func buildVersion() (version, revision, built string) {
+	version, revision, built = gomiabdns.Version, commit, date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && revision == "":
+				revision = setting.Value
+			case setting.Key == "vcs.time" && built == "":
+				built = setting.Value
+			}
+		}
+	}
+	if revision == "" {
+		revision = "unknown"
+	}
+	if built == "" {
+		built = "unknown"
+	}
+	return version, revision, built
+}
+
+// writeVersion writes the version, commit and build date of the binary to w.
+func writeVersion(w io.Writer) {
+	version, revision, built := buildVersion()
+	fmt.Fprintf(w, "miabdns %s (commit %s, built %s)\n", version, revision, built)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/luv2code/gomiabdns"
+	"golang.org/x/exp/slices"
+)
+
+// ANSI colors of the lines watch prints for added and deleted records.
+const (
+	colorGreen = "\x1b[32m"
+	colorRed   = "\x1b[31m"
+	colorReset = "\x1b[0m"
+)
+
+// watchRecords polls the records of -rname and -rtype, or all records, every -interval and prints
+// the ones added and deleted since the last poll, until interrupted. -timeout applies to each poll.
+// Failed polls are reported and retried at the next interval, except the first one, which usually
+// means the credentials or url are wrong.
+func watchRecords(ctx context.Context, c *gomiabdns.Client) error {
+	if interval <= 0 {
+		return newUsageError("The interval argument must be positive.")
+	}
+	records, err := pollRecords(ctx, c)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "watching %d records, polling every %s. Press Ctrl-C to stop.\n", len(records), interval)
+	color := isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		current, err := pollRecords(ctx, c)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "%s poll failed: %s\n", time.Now().Format(time.RFC3339), err)
+			continue
+		}
+		deleted, added := recordChanges(records, current)
+		records = current
+		if len(deleted)+len(added) > 0 {
+			writeRecordChanges(os.Stdout, deleted, added, color)
+		}
+	}
+}
+
+// pollRecords fetches the watched records, giving up after -timeout.
+func pollRecords(ctx context.Context, c *gomiabdns.Client) ([]gomiabdns.DNSRecord, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	records, err := c.GetHosts(ctx, recordName, gomiabdns.RecordType(recordType))
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("request timed out after %s", timeout)
+	}
+	return records, err
+}
+
+// recordChanges returns the records of before that aren't in after and those of after that
+// weren't in before. Names are compared normalized, as gomiabdns.NormalizeName does.
+func recordChanges(before, after []gomiabdns.DNSRecord) (deleted, added []gomiabdns.DNSRecord) {
+	key := func(r gomiabdns.DNSRecord) gomiabdns.DNSRecord {
+		return gomiabdns.DNSRecord{QualifiedName: gomiabdns.NormalizeName(r.QualifiedName), RecordType: r.RecordType, Value: r.Value}
+	}
+	count := map[gomiabdns.DNSRecord]int{}
+	for _, r := range before {
+		count[key(r)]++
+	}
+	for _, r := range after {
+		if count[key(r)] > 0 {
+			count[key(r)]--
+		} else {
+			added = append(added, r)
+		}
+	}
+	for _, r := range before {
+		if count[key(r)] > 0 {
+			count[key(r)]--
+			deleted = append(deleted, r)
+		}
+	}
+	return deleted, added
+}
+
+// writeRecordChanges prints the time followed by deleted records prefixed - and added records
+// prefixed +, in red and green when color is set.
+func writeRecordChanges(w io.Writer, deleted, added []gomiabdns.DNSRecord, color bool) {
+	fmt.Fprintln(w, time.Now().Format(time.RFC3339))
+	byName := func(a, b gomiabdns.DNSRecord) int {
+		return strings.Compare(a.String(), b.String())
+	}
+	slices.SortFunc(deleted, byName)
+	slices.SortFunc(added, byName)
+	for _, r := range deleted {
+		writeColored(w, fmt.Sprintf("- %s", r), colorRed, color)
+	}
+	for _, r := range added {
+		writeColored(w, fmt.Sprintf("+ %s", r), colorGreen, color)
+	}
+}
+
+func writeColored(w io.Writer, line, ansi string, color bool) {
+	if color {
+		line = ansi + line + colorReset
+	}
+	fmt.Fprintln(w, line)
+}
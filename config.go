@@ -0,0 +1,81 @@
+package gomiabdns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// xfrPrefix marks an entry of the secondary nameserver list that only allows zone transfers to
+// an IP address or network, rather than naming a nameserver.
+const xfrPrefix = "xfr:"
+
+// GetSecondaryNameservers returns the external secondary nameservers configured on the box.
+// Entries starting with "xfr:" allow zone transfers to the IP address or network that follows.
+func (c *Client) GetSecondaryNameservers(ctx context.Context) ([]string, error) {
+	apiResp, err := c.doRequest(ctx, http.MethodGet, c.adminUrl("dns", "secondary-nameserver").String(), "")
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Hostnames []string `json:"hostnames"`
+	}
+	if err := unmarshalJSON(apiResp, &result); err != nil {
+		return nil, err
+	}
+	return result.Hostnames, nil
+}
+
+// SetSecondaryNameservers replaces the external secondary nameservers configured on the box.
+// Pass an empty slice to remove them all.
+func (c *Client) SetSecondaryNameservers(ctx context.Context, hostnames []string) error {
+	for _, hostname := range hostnames {
+		if err := validateSecondaryNameserver(hostname); err != nil {
+			return err
+		}
+	}
+	form := url.Values{"hostnames": {strings.Join(hostnames, ",")}}
+	_, err := c.doFormRequest(ctx, http.MethodPost, c.adminUrl("dns", "secondary-nameserver").String(), form)
+	return err
+}
+
+// DNSConfig is the DNS configuration of the box as a whole.
+type DNSConfig struct {
+	// Zones are the zones the box serves.
+	Zones []string
+	// SecondaryNameservers are the external secondary nameservers, as GetSecondaryNameservers returns them.
+	SecondaryNameservers []string
+}
+
+// GetDNSConfig returns the zones and secondary nameservers of the box in one call, for ex. to
+// render a dashboard. It leaves out DNSSEC, which the box only reports in the free-form text of
+// its status checks.
+func (c *Client) GetDNSConfig(ctx context.Context) (DNSConfig, error) {
+	zones, err := c.GetZones(ctx)
+	if err != nil {
+		return DNSConfig{}, err
+	}
+	nameservers, err := c.GetSecondaryNameservers(ctx)
+	if err != nil {
+		return DNSConfig{}, err
+	}
+	return DNSConfig{Zones: zones, SecondaryNameservers: nameservers}, nil
+}
+
+func validateSecondaryNameserver(hostname string) error {
+	if network, ok := strings.CutPrefix(hostname, xfrPrefix); ok {
+		if net.ParseIP(network) == nil {
+			if _, _, err := net.ParseCIDR(network); err != nil {
+				return fmt.Errorf("Invalid secondary nameserver %q: not an IP address or network", hostname)
+			}
+		}
+		return nil
+	}
+	if err := validateHostname(hostname); err != nil {
+		return fmt.Errorf("Invalid secondary nameserver %q: %w", hostname, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package gomiabdns
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// credentials are the fields of a credentials file.
+type credentials struct {
+	Url      string `json:"url"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	TOTP     string `json:"totp"`
+}
+
+// NewFromCredentialsFile returns a new client configured from the file at path, for ex. for a cron
+// job on the box. The file is either a JSON object or lines of key=value, with # comments:
+//
+//	url=https://box.example.com/admin/dns/custom
+//	email=admin@example.com
+//	password=secret
+//	totp=JBSWY3DPEHPK3PXP
+//
+// Keys are case insensitive and may have a MIAB_ prefix, so the file can double as an environment
+// file for the miabdns command. url, email and password are required. totp is the base32 secret or
+// otpauth:// URI of the account's two-factor authentication, if enabled. opts are applied after
+// the file's settings. Keep the file readable only by its owner.
+func NewFromCredentialsFile(path string, opts ...Option) (*Client, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading credentials file: %w", err)
+	}
+	creds, err := parseCredentials(data)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid credentials file %s: %w", path, err)
+	}
+	if creds.Url == "" || creds.Email == "" || creds.Password == "" {
+		return nil, fmt.Errorf("Missing parameters in credentials file %s. url, email and password are required.", path)
+	}
+	var fileOpts []Option
+	if strings.HasPrefix(creds.TOTP, "otpauth:") {
+		fileOpts = append(fileOpts, WithTOTPURI(creds.TOTP))
+	} else if creds.TOTP != "" {
+		fileOpts = append(fileOpts, WithTOTPSecret(creds.TOTP))
+	}
+	return NewWithOptions(creds.Url, creds.Email, creds.Password, append(fileOpts, opts...)...)
+}
+
+// parseCredentials parses a credentials file, telling JSON from key=value lines by its first character.
+func parseCredentials(data []byte) (credentials, error) {
+	var creds credentials
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &creds); err != nil {
+			return credentials{}, err
+		}
+		return creds, nil
+	}
+	fields := map[string]*string{"url": &creds.Url, "email": &creds.Email, "password": &creds.Password, "totp": &creds.TOTP}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return credentials{}, fmt.Errorf("line %d: expected key=value", number)
+		}
+		key = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(key)), "miab_")
+		field, ok := fields[key]
+		if !ok {
+			return credentials{}, fmt.Errorf("line %d: unknown key %s", number, key)
+		}
+		*field = unquote(strings.TrimSpace(value))
+	}
+	return creds, scanner.Err()
+}
+
+// unquote removes the single or double quotes around value, if it has them.
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,137 @@
+package gomiabdns
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrRecordNotFound is returned by GetRecord when no record matches.
+var ErrRecordNotFound = errors.New("record not found")
+
+// ErrTOTPRequired is returned when the account has two-factor authentication enabled and the
+// client has neither a TOTPSecret nor a TOTPCode.
+var ErrTOTPRequired = errors.New("two-factor authentication code required")
+
+// ErrTOTPRejected is returned when the box rejects the two-factor authentication code of a login,
+// because the client's TOTPSecret is wrong, its clock is off or its TOTPCode was already used.
+var ErrTOTPRejected = errors.New("two-factor authentication code rejected")
+
+// ErrNotAdmin is returned by Login, and by requests that log in first, when the credentials are
+// valid but the account isn't an administrator of the box, which the DNS api requires.
+var ErrNotAdmin = errors.New("account does not have admin privileges")
+
+// ErrAPIKeyRejected is returned when the box refuses the api key of a client created with
+// NewWithAPIKey, for ex. because it expired. Such a client can't log in again without a password.
+var ErrAPIKeyRejected = errors.New("api key rejected")
+
+// ErrNoChange is returned by the mutation methods of a client with NoChangeError set when the box
+// reports that the records already matched the request. The MutationResult is returned with it.
+var ErrNoChange = errors.New("no change")
+
+// ErrResponseTooLarge is returned when a response body is larger than the client's MaxResponseSize,
+// for ex. because a misconfigured proxy sends something else than the box's api.
+var ErrResponseTooLarge = errors.New("response too large")
+
+// maxReasonLen is the most of a plain text response body that is used as an APIError's Reason.
+const maxReasonLen = 512
+
+// APIError is returned when the API responds with an error or with a body that can't be understood.
+// Use errors.As to inspect it.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Reason is the box's explanation of the failure, if it gave one.
+	Reason string
+	// Body is the raw response body.
+	Body []byte
+	// RetryAfter is how long the Retry-After header of a 429 or 503 response asked to wait, or 0.
+	RetryAfter time.Duration
+	// RequestID is the id the request was sent with in its X-Request-ID header, to look it up in
+	// the box's logs.
+	RequestID string
+
+	// err is the error decoding the body, if that is what went wrong.
+	err error
+}
+
+// apiStatus is the JSON body the box sends with some responses.
+type apiStatus struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+func newAPIError(statusCode int, body []byte) *APIError {
+	return &APIError{
+		StatusCode: statusCode,
+		Reason:     parseReason(body),
+		Body:       body,
+	}
+}
+
+// parseReason pulls the reason out of a JSON status body or the title of an HTML error page, as a
+// proxy in front of the box sends, falling back to the start of a plain text body.
+func parseReason(body []byte) string {
+	var status apiStatus
+	if err := json.Unmarshal(body, &status); err == nil && status.Reason != "" {
+		return status.Reason
+	}
+	reason := strings.TrimSpace(string(body))
+	if strings.HasPrefix(reason, "<") {
+		if _, title, ok := strings.Cut(reason, "<title>"); ok {
+			if title, _, ok := strings.Cut(title, "</title>"); ok && strings.TrimSpace(title) != "" {
+				reason = strings.TrimSpace(title)
+			}
+		}
+	}
+	if len(reason) > maxReasonLen {
+		// Cut at the start of a rune rather than in the middle of one.
+		cut := maxReasonLen
+		for cut > 0 && !utf8.RuneStart(reason[cut]) {
+			cut--
+		}
+		reason = reason[:cut] + "..."
+	}
+	return reason
+}
+
+func (e *APIError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("server returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("server returned %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Reason)
+}
+
+// Unwrap returns the error decoding the body of a response that couldn't be understood, so it
+// can be inspected with errors.As, for ex. as a *json.SyntaxError.
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// Is reports a 401 response asking for a two-factor authentication code as ErrTOTPRequired.
+func (e *APIError) Is(target error) bool {
+	return target == ErrTOTPRequired && e.StatusCode == http.StatusUnauthorized &&
+		strings.Contains(e.Reason, "missing-totp-token")
+}
+
+// parseRetryAfter returns the wait a Retry-After header asks for, given in seconds or as an HTTP
+// date, which is relative to now.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package gomiabdns_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/luv2code/gomiabdns"
+)
+
+func TestAPIErrorReason(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantReason string
+	}{
+		{"json status", `{"status": "error", "reason": "Invalid email address."}`, "Invalid email address."},
+		{"plain text", "  Something broke.\n", "Something broke."},
+		{"long plain text", strings.Repeat("x", 600), strings.Repeat("x", 512) + "..."},
+		// 'é' is two bytes, so byte 512 is in the middle of the 256th one.
+		{"long multi-byte text", "x" + strings.Repeat("é", 300), "x" + strings.Repeat("é", 255) + "..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			c, err := gomiabdns.New(srv.URL, "admin@example.com", "password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = c.GetHosts(context.Background(), "", "")
+			var apiErr *gomiabdns.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("GetHosts error = %v, want an *APIError", err)
+			}
+			if apiErr.StatusCode != http.StatusBadRequest {
+				t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, http.StatusBadRequest)
+			}
+			if apiErr.Reason != tt.wantReason {
+				t.Errorf("Reason = %q, want %q", apiErr.Reason, tt.wantReason)
+			}
+			if !utf8.ValidString(apiErr.Reason) {
+				t.Errorf("Reason %q isn't valid UTF-8", apiErr.Reason)
+			}
+		})
+	}
+}
+
+// nginxBadGateway is the page nginx, which the box runs in front of its api, sends when the api is down.
+const nginxBadGateway = `<html>
+<head><title>502 Bad Gateway</title></head>
+<body>
+<center><h1>502 Bad Gateway</h1></center>
+<hr><center>nginx</center>
+</body>
+</html>
+`
+
+func TestUnexpectedResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int
+		wantReason string
+	}{
+		{name: "html error page", status: http.StatusBadGateway, body: nginxBadGateway, wantStatus: http.StatusBadGateway, wantReason: "502 Bad Gateway"},
+		{name: "html error page without title", status: http.StatusBadGateway, body: "<html><body>down</body></html>", wantStatus: http.StatusBadGateway, wantReason: "<html><body>down</body></html>"},
+		{name: "empty body", status: http.StatusOK, body: "", wantStatus: http.StatusOK, wantReason: "empty response, expected JSON"},
+		{name: "blank body", status: http.StatusOK, body: " \n", wantStatus: http.StatusOK, wantReason: "empty response, expected JSON"},
+		{name: "html page", status: http.StatusOK, body: "<html><title>Mail-in-a-Box Control Panel</title></html>", wantStatus: http.StatusOK, wantReason: "got an HTML page, expected JSON. Check the api url"},
+		{name: "invalid json", status: http.StatusOK, body: "[{", wantStatus: http.StatusOK, wantReason: "Error while decoding json: unexpected end of JSON input"},
+	}
+	calls := map[string]func(c *gomiabdns.Client) error{
+		"GetHosts": func(c *gomiabdns.Client) error {
+			_, err := c.GetHosts(context.Background(), "", "")
+			return err
+		},
+		"GetZones": func(c *gomiabdns.Client) error {
+			_, err := c.GetZones(context.Background())
+			return err
+		},
+	}
+	for _, tt := range tests {
+		for name, call := range calls {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(tt.body))
+				}))
+				defer srv.Close()
+				c, err := gomiabdns.New(srv.URL, "admin@example.com", "password")
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = call(c)
+				var apiErr *gomiabdns.APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("%s error = %v, want an *APIError", name, err)
+				}
+				if apiErr.StatusCode != tt.wantStatus {
+					t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, tt.wantStatus)
+				}
+				if apiErr.Reason != tt.wantReason {
+					t.Errorf("Reason = %q, want %q", apiErr.Reason, tt.wantReason)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package gomiabdns
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// NormalizeName returns name lowercased and without a trailing dot. The API isn't consistent about
+// either, so names should be normalized before they are compared.
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// SameValue reports whether two values of a recordType record are the same, as GetRecord and
+// EnsureHost compare them. The values of CNAME and NS records are names and are compared
+// normalized, so "host.example.com" and "Host.example.com." are the same target.
+func SameValue(recordType RecordType, a, b string) bool {
+	switch recordType {
+	case CNAME, NS:
+		return NormalizeName(a) == NormalizeName(b)
+	}
+	return a == b
+}
+
+// FilterByType returns the records of records with recordType, in their original order.
+func FilterByType(records []DNSRecord, recordType RecordType) []DNSRecord {
+	return filterRecords(records, func(r DNSRecord) bool { return r.RecordType == recordType })
+}
+
+// FilterByZone returns the records of records that belong to zone, in their original order.
+// Zones are compared as NormalizeName does.
+func FilterByZone(records []DNSRecord, zone string) []DNSRecord {
+	zone = NormalizeName(zone)
+	return filterRecords(records, func(r DNSRecord) bool { return NormalizeName(r.Zone) == zone })
+}
+
+// FilterByName returns the records of records whose name is name, in their original order.
+// Names are compared as NormalizeName does.
+func FilterByName(records []DNSRecord, name string) []DNSRecord {
+	name = NormalizeName(name)
+	return filterRecords(records, func(r DNSRecord) bool { return NormalizeName(r.QualifiedName) == name })
+}
+
+// RecordOrder is the order GetHosts returns records in. See Client.Order.
+type RecordOrder int
+
+const (
+	// OrderAsReturned keeps the records in the order the API returned them.
+	OrderAsReturned RecordOrder = iota
+	// OrderByName sorts the records as SortByName does, the order of the box's admin page.
+	OrderByName
+	// OrderByCreated sorts the records as SortByCreated does.
+	OrderByCreated
+)
+
+// sortRecords sorts records in place in order.
+func sortRecords(records []DNSRecord, order RecordOrder) {
+	switch order {
+	case OrderByName:
+		SortByName(records)
+	case OrderByCreated:
+		SortByCreated(records)
+	}
+}
+
+// SortByName sorts records in place in the order the box's admin page lists them by name, using
+// their SortOrder. Records with the same position keep their relative order.
+func SortByName(records []DNSRecord) {
+	slices.SortStableFunc(records, func(a, b DNSRecord) int {
+		return a.SortOrder.ByName - b.SortOrder.ByName
+	})
+}
+
+// SortByCreated sorts records in place in the order they were created, using their SortOrder.
+// Records with the same position keep their relative order.
+func SortByCreated(records []DNSRecord) {
+	slices.SortStableFunc(records, func(a, b DNSRecord) int {
+		return a.SortOrder.ByCreated - b.SortOrder.ByCreated
+	})
+}
+
+func filterRecords(records []DNSRecord, keep func(DNSRecord) bool) []DNSRecord {
+	var result []DNSRecord
+	for _, record := range records {
+		if keep(record) {
+			result = append(result, record)
+		}
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package gomiabdns_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/luv2code/gomiabdns"
+	"golang.org/x/exp/slices"
+)
+
+// sortedRecord returns a record with value and the given sort orders.
+func sortedRecord(value string, byName, byCreated int) gomiabdns.DNSRecord {
+	r := gomiabdns.DNSRecord{QualifiedName: "www.example.com", RecordType: gomiabdns.TXT, Value: value}
+	r.SortOrder.ByName = byName
+	r.SortOrder.ByCreated = byCreated
+	return r
+}
+
+func TestSortStability(t *testing.T) {
+	// Records with the same position, as the box reports for records of the same name, must keep
+	// their relative order.
+	records := []gomiabdns.DNSRecord{
+		sortedRecord("a", 2, 1),
+		sortedRecord("b", 1, 1),
+		sortedRecord("c", 2, 0),
+		sortedRecord("d", 1, 2),
+		sortedRecord("e", 0, 1),
+		sortedRecord("f", 2, 0),
+	}
+	tests := []struct {
+		name string
+		sort func([]gomiabdns.DNSRecord)
+		want []string
+	}{
+		{"SortByName", gomiabdns.SortByName, []string{"e", "b", "d", "a", "c", "f"}},
+		{"SortByCreated", gomiabdns.SortByCreated, []string{"c", "f", "a", "b", "e", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := slices.Clone(records)
+			tt.sort(sorted)
+			var got []string
+			for _, r := range sorted {
+				got = append(got, r.Value)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%s sorted the records as %q, want %q", tt.name, got, tt.want)
+			}
+			// Sorting sorted records again changes nothing.
+			tt.sort(sorted)
+			for i, r := range sorted {
+				if r.Value != tt.want[i] {
+					t.Fatalf("%s isn't stable on sorted records: got %q at %d, want %q", tt.name, r.Value, i, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"www.example.com", "www.example.com"},
+		{"www.example.com.", "www.example.com"},
+		{"WWW.Example.COM", "www.example.com"},
+		{"WWW.Example.COM.", "www.example.com"},
+		{"example.com..", "example.com."},
+		{".", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := gomiabdns.NormalizeName(tt.name); got != tt.want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// mixedCaseRecords are records as the box may return them, with names in mixed case and with or
+// without a trailing dot.
+var mixedCaseRecords = []gomiabdns.DNSRecord{
+	{QualifiedName: "www.example.com", RecordType: gomiabdns.A, Value: "1.2.3.4", Zone: "example.com"},
+	{QualifiedName: "WWW.Example.com.", RecordType: gomiabdns.A, Value: "1.2.3.5", Zone: "Example.com."},
+	{QualifiedName: "Mail.Example.COM", RecordType: gomiabdns.CNAME, Value: "Target.Example.COM.", Zone: "example.com"},
+	{QualifiedName: "www.example.org.", RecordType: gomiabdns.A, Value: "1.2.3.6", Zone: "example.org"},
+}
+
+func TestFilterByNameAndZone(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter func([]gomiabdns.DNSRecord, string) []gomiabdns.DNSRecord
+		arg    string
+		want   []string
+	}{
+		{"name", gomiabdns.FilterByName, "www.example.com", []string{"1.2.3.4", "1.2.3.5"}},
+		{"name with trailing dot", gomiabdns.FilterByName, "www.example.com.", []string{"1.2.3.4", "1.2.3.5"}},
+		{"name in mixed case", gomiabdns.FilterByName, "MAIL.example.com", []string{"Target.Example.COM."}},
+		{"name of another zone", gomiabdns.FilterByName, "WWW.example.ORG", []string{"1.2.3.6"}},
+		{"zone", gomiabdns.FilterByZone, "EXAMPLE.com.", []string{"1.2.3.4", "1.2.3.5", "Target.Example.COM."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range tt.filter(mixedCaseRecords, tt.arg) {
+				got = append(got, r.Value)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("filtering by %q returned %q, want %q", tt.arg, got, tt.want)
+			}
+		})
+	}
+}
+
+// fixtureServer is a box that lists records for every GET and counts the other requests.
+func fixtureServer(records []gomiabdns.DNSRecord, mutations *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			mutations.Add(1)
+			_, _ = w.Write([]byte("updated DNS: example.com"))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(records)
+	}))
+}
+
+func TestGetRecordNormalizesNames(t *testing.T) {
+	var mutations atomic.Int32
+	srv := fixtureServer(mixedCaseRecords, &mutations)
+	defer srv.Close()
+	c, err := gomiabdns.New(srv.URL, "admin@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		rtype     gomiabdns.RecordType
+		value     string
+		wantFound bool
+	}{
+		{"www.example.com", gomiabdns.A, "1.2.3.5", true},
+		{"WWW.EXAMPLE.COM.", gomiabdns.A, "1.2.3.4", true},
+		{"mail.example.com", gomiabdns.CNAME, "target.example.com", true},
+		{"mail.example.com.", gomiabdns.CNAME, "TARGET.example.com.", true},
+		{"www.example.com", gomiabdns.A, "1.2.3.6", false},
+		{"www.example.com", gomiabdns.CNAME, "1.2.3.4", false},
+	}
+	for _, tt := range tests {
+		record, err := c.GetRecord(context.Background(), tt.name, tt.rtype, tt.value)
+		if tt.wantFound && err != nil {
+			t.Errorf("GetRecord(%q, %s, %q): %v", tt.name, tt.rtype, tt.value, err)
+		}
+		if !tt.wantFound && !errors.Is(err, gomiabdns.ErrRecordNotFound) {
+			t.Errorf("GetRecord(%q, %s, %q) = %v, %v, want ErrRecordNotFound", tt.name, tt.rtype, tt.value, record, err)
+		}
+	}
+
+	// A CNAME that only differs in case and the trailing dot is already there.
+	changed, err := c.EnsureHost(context.Background(), "mail.example.com", gomiabdns.CNAME, "target.example.com")
+	if err != nil || changed {
+		t.Errorf("EnsureHost = %v, %v, want an unchanged record", changed, err)
+	}
+	if got := mutations.Load(); got != 0 {
+		t.Errorf("EnsureHost sent %d changes, want none", got)
+	}
+}
+
+func TestSameValue(t *testing.T) {
+	tests := []struct {
+		rtype gomiabdns.RecordType
+		a, b  string
+		want  bool
+	}{
+		{gomiabdns.CNAME, "target.example.com", "Target.Example.com.", true},
+		{gomiabdns.NS, "ns1.example.com.", "ns1.example.com", true},
+		{gomiabdns.CNAME, "target.example.com", "other.example.com", false},
+		{gomiabdns.A, "1.2.3.4", "1.2.3.4", true},
+		{gomiabdns.TXT, "v=spf1 -all", "V=spf1 -all", false},
+		{gomiabdns.MX, "10 mail.example.com", "10 mail.example.com.", false},
+	}
+	for _, tt := range tests {
+		if got := gomiabdns.SameValue(tt.rtype, tt.a, tt.b); got != tt.want {
+			t.Errorf("SameValue(%s, %q, %q) = %v, want %v", tt.rtype, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
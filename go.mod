@@ -2,4 +2,7 @@ module github.com/luv2code/gomiabdns
 
 go 1.21.0
 
-require golang.org/x/exp v0.0.0-20230905200255-921286631fa9
+require (
+	golang.org/x/exp v0.0.0-20230905200255-921286631fa9
+	golang.org/x/time v0.5.0
+)
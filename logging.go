@@ -0,0 +1,79 @@
+package gomiabdns
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// logRequest records a finished request on the client's Logger, if it has one. requestID is the id
+// the client sent and responseID the one the box sent back, if any.
+func (c *Client) logRequest(ctx context.Context, method, requestURL, requestID, responseID string, status int, duration time.Duration, err error) {
+	if c.Logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("url", redactUrl(requestURL)),
+		slog.Int("status", status),
+		slog.Duration("duration", duration),
+		slog.String("request_id", requestID),
+	}
+	if responseID != "" && responseID != requestID {
+		attrs = append(attrs, slog.String("response_request_id", responseID))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	c.Logger.LogAttrs(ctx, slog.LevelDebug, "miab request", attrs...)
+}
+
+// logResponse records the message the box sent back for a mutation, which says what it changed.
+func (c *Client) logResponse(ctx context.Context, method, requestURL string, body []byte) {
+	if c.Logger == nil {
+		return
+	}
+	c.Logger.LogAttrs(ctx, slog.LevelDebug, "miab response",
+		slog.String("method", method),
+		slog.String("url", redactUrl(requestURL)),
+		slog.String("body", strings.TrimSpace(string(body))),
+	)
+}
+
+// logDryRun records a request that dry run mode kept from being sent on the client's Logger, if it has one.
+func (c *Client) logDryRun(ctx context.Context, method, requestURL, value string) {
+	if c.Logger == nil {
+		return
+	}
+	c.Logger.LogAttrs(ctx, slog.LevelInfo, "miab dry run",
+		slog.String("method", method),
+		slog.String("url", redactUrl(requestURL)),
+		slog.String("value", value),
+	)
+}
+
+// newRequestID returns a random (version 4) UUID to identify a request, or "" if there is no
+// randomness to make one from.
+func newRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ""
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+// redactUrl removes the credentials from requestURL so it can be logged.
+func redactUrl(requestURL string) string {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return requestURL
+	}
+	u.User = nil
+	return u.String()
+}
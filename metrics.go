@@ -0,0 +1,20 @@
+package gomiabdns
+
+import "time"
+
+// Metrics receives an observation for every request made to the API, retries included. It lets
+// callers feed request counts, error counts and latencies into a metrics library of their choice,
+// for ex. a Prometheus counter and histogram labeled by method and status.
+type Metrics interface {
+	// ObserveRequest is called when a request finishes. status is the HTTP status code, or 0 if
+	// no response was received. It may be called from several goroutines at once.
+	ObserveRequest(method string, status int, duration time.Duration)
+}
+
+// observeRequest reports a finished request to the client's Metrics, if it has any.
+func (c *Client) observeRequest(method string, status int, duration time.Duration) {
+	if c.Metrics == nil {
+		return
+	}
+	c.Metrics.ObserveRequest(method, status, duration)
+}
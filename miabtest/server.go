@@ -0,0 +1,438 @@
+// Package miabtest provides a fake Mail-In-A-Box admin API for testing code that uses gomiabdns.
+package miabtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/luv2code/gomiabdns"
+	"golang.org/x/exp/slices"
+)
+
+// Credentials accepted by a Server.
+const (
+	Email    = "admin@example.com"
+	Password = "password"
+)
+
+// recordTypes are the record types the box accepts for custom records.
+var recordTypes = []gomiabdns.RecordType{
+	gomiabdns.A,
+	gomiabdns.AAAA,
+	gomiabdns.CAA,
+	gomiabdns.CNAME,
+	gomiabdns.MX,
+	gomiabdns.NS,
+	gomiabdns.TXT,
+	gomiabdns.SRV,
+	gomiabdns.SSHFP,
+}
+
+// Request is a request the Server received.
+type Request struct {
+	Method string
+	Path   string
+	Body   string
+	Header http.Header
+}
+
+// Server is a fake Mail-In-A-Box admin API backed by in-memory state. It implements login,
+// logout, two-factor authentication, the custom DNS endpoints, zones, zonefiles, the DNS dump and
+// the secondary nameserver setting.
+// Point a client at APIURL and authenticate with Email and Password.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	zones       []string
+	records     []gomiabdns.DNSRecord
+	created     int
+	secondaryNS []string
+	privileges  []string
+	apikey      string
+	totpSecret  string
+	lastTOTP    string
+	requests    []Request
+}
+
+// NewServer starts a Server serving the given zones. Close it when done.
+func NewServer(zones ...string) *Server {
+	s := &Server{zones: zones, privileges: []string{"admin"}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// APIURL returns the url of the custom DNS endpoint, the url gomiabdns.New expects.
+func (s *Server) APIURL() string {
+	return s.URL + "/admin/dns/custom"
+}
+
+// AddZone makes the server serve zone.
+func (s *Server) AddZone(zone string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.zones, zone) {
+		s.zones = append(s.zones, zone)
+	}
+}
+
+// SetPrivileges sets the privileges login reports for the account. It has the admin privilege
+// by default; call SetPrivileges() without any to log in as a plain mail user, whose requests to
+// the api are refused with 403 Forbidden.
+func (s *Server) SetPrivileges(privileges ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.privileges = append([]string{}, privileges...)
+}
+
+// SetRecords replaces the custom records. Their zones are filled in from the served zones.
+func (s *Server) SetRecords(records []gomiabdns.DNSRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = nil
+	for _, r := range records {
+		s.addRecord(r.QualifiedName, r.RecordType, r.Value)
+	}
+}
+
+// Records returns the current custom records, in the order the API lists them.
+func (s *Server) Records() []gomiabdns.DNSRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listRecords()
+}
+
+// Requests returns every request received so far, oldest first.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// RequestsTo returns the requests received with method for path, for ex. "/admin/dns/custom/a.example.com/A".
+func (s *Server) RequestsTo(method, path string) []Request {
+	var result []Request
+	for _, r := range s.Requests() {
+		if r.Method == method && r.Path == path {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// ResetRequests forgets the requests received so far.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+var customPath = regexp.MustCompile(`^/admin/dns/custom(?:/([^/]+))?(?:/([^/]+))?/?$`)
+
+var hostnamesSeparator = regexp.MustCompile(`[, ]+`)
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Body: string(body), Header: r.Header.Clone()})
+
+	email, secret, ok := r.BasicAuth()
+	if r.URL.Path == "/admin/login" && r.Method == http.MethodPost {
+		s.login(w, r, ok && email == Email && secret == Password)
+		return
+	}
+	authorized := ok && email == Email && (secret == Password || (s.apikey != "" && secret == s.apikey))
+	if authorized && secret == Password {
+		if reason := s.checkTOTP(r); reason != "" {
+			http.Error(w, reason, http.StatusUnauthorized)
+			return
+		}
+	}
+	if !authorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Mail-in-a-Box Management Server"`)
+		http.Error(w, "Incorrect username or password", http.StatusUnauthorized)
+		return
+	}
+	if !slices.Contains(s.privileges, "admin") {
+		http.Error(w, "You are not an administrator.", http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/admin/logout" && r.Method == http.MethodPost:
+		if secret == s.apikey {
+			s.apikey = ""
+		}
+		writeJSON(w, map[string]string{"status": "ok"})
+	case r.URL.Path == "/admin/dns/zones" && r.Method == http.MethodGet:
+		writeJSON(w, s.sortedZones())
+	case r.URL.Path == "/admin/dns/dump" && r.Method == http.MethodGet:
+		s.dump(w)
+	case strings.HasPrefix(r.URL.Path, "/admin/dns/zonefile/") && r.Method == http.MethodGet:
+		s.zonefile(w, strings.TrimPrefix(r.URL.Path, "/admin/dns/zonefile/"))
+	case r.URL.Path == "/admin/dns/secondary-nameserver":
+		s.secondaryNameserver(w, r, body)
+	case customPath.MatchString(r.URL.Path):
+		match := customPath.FindStringSubmatch(r.URL.Path)
+		s.custom(w, r.Method, match[1], gomiabdns.RecordType(strings.ToUpper(match[2])), strings.TrimSpace(string(body)))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request, ok bool) {
+	if !ok {
+		writeJSON(w, map[string]string{"status": "invalid", "reason": "Incorrect email address or password."})
+		return
+	}
+	switch s.checkTOTP(r) {
+	case "missing-totp-token":
+		writeJSON(w, map[string]string{"status": "missing-totp-token", "reason": "Missing two-factor authentication token."})
+		return
+	case "invalid-totp-token":
+		writeJSON(w, map[string]string{"status": "invalid", "reason": "invalid-totp-token"})
+		return
+	}
+	if s.apikey == "" {
+		key := make([]byte, 16)
+		_, _ = rand.Read(key)
+		s.apikey = hex.EncodeToString(key)
+	}
+	writeJSON(w, map[string]any{
+		"status":     "ok",
+		"email":      Email,
+		"privileges": s.privileges,
+		"api_key":    s.apikey,
+	})
+}
+
+func (s *Server) custom(w http.ResponseWriter, method, qname string, rtype gomiabdns.RecordType, value string) {
+	if qname == "" {
+		if method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, s.listRecords())
+		return
+	}
+	if rtype == "" {
+		rtype = gomiabdns.A
+	}
+	if !slices.Contains(recordTypes, rtype) {
+		http.Error(w, fmt.Sprintf("Unknown record type '%s'.", rtype), http.StatusBadRequest)
+		return
+	}
+	switch method {
+	case http.MethodGet:
+		var result []gomiabdns.DNSRecord
+		for _, r := range s.listRecords() {
+			if r.QualifiedName == qname && r.RecordType == rtype {
+				result = append(result, r)
+			}
+		}
+		writeJSON(w, append([]gomiabdns.DNSRecord{}, result...))
+		return
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	zone := s.zoneOf(qname)
+	if zone == "" {
+		http.Error(w, fmt.Sprintf("%s is not a domain name or a subdomain of a domain name managed by this box.", qname), http.StatusBadRequest)
+		return
+	}
+	if method != http.MethodDelete && value == "" {
+		http.Error(w, "No value for the record provided.", http.StatusBadRequest)
+		return
+	}
+	changed := false
+	switch method {
+	case http.MethodPost:
+		changed = s.addRecord(qname, rtype, value)
+	case http.MethodPut:
+		// Like the box, setting the only value a name and type already have changes nothing.
+		if values := s.values(qname, rtype); len(values) != 1 || values[0] != value {
+			s.deleteRecords(qname, rtype, "")
+			changed = s.addRecord(qname, rtype, value)
+		}
+	case http.MethodDelete:
+		changed = s.deleteRecords(qname, rtype, value)
+	}
+	if !changed {
+		fmt.Fprint(w, "OK")
+		return
+	}
+	fmt.Fprintf(w, "updated DNS: %s\n", zone)
+}
+
+func (s *Server) zonefile(w http.ResponseWriter, zone string) {
+	if !slices.Contains(s.zones, zone) {
+		http.Error(w, fmt.Sprintf("%s is not a domain name that corresponds to a zone.", zone), http.StatusBadRequest)
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "$ORIGIN %s.\n$TTL 86400\n", zone)
+	fmt.Fprintf(&b, "@ IN SOA ns1.%s. hostmaster.%s. (\n\t2024010100 ; serial\n\t7200 ; refresh\n\t3600 ; retry\n\t1209600 ; expire\n\t86400 ; minimum\n)\n", zone, zone)
+	fmt.Fprintf(&b, "@ IN NS ns1.%s.\n", zone)
+	for _, r := range s.listRecords() {
+		if r.Zone == zone {
+			fmt.Fprintf(&b, "%s. IN %s %s\n", r.QualifiedName, r.RecordType, r.Value)
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, b.String())
+}
+
+// dump writes the records of every zone in the format of the box's /admin/dns/dump endpoint: an NS
+// record the box generates, followed by the custom records.
+func (s *Server) dump(w http.ResponseWriter) {
+	result := []any{}
+	for _, zone := range s.sortedZones() {
+		records := []gomiabdns.DumpRecord{{
+			QualifiedName: zone,
+			RecordType:    gomiabdns.NS,
+			Value:         "ns1." + zone + ".",
+			Explanation:   "Required. The box is the zone's nameserver.",
+		}}
+		for _, r := range s.listRecords() {
+			if r.Zone == zone {
+				records = append(records, gomiabdns.DumpRecord{
+					QualifiedName: r.QualifiedName,
+					RecordType:    r.RecordType,
+					Value:         r.Value,
+					Explanation:   "(Set by user.)",
+				})
+			}
+		}
+		result = append(result, []any{zone, records})
+	}
+	writeJSON(w, result)
+}
+
+func (s *Server) secondaryNameserver(w http.ResponseWriter, r *http.Request, body []byte) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string][]string{"hostnames": append([]string{}, s.secondaryNS...)})
+	case http.MethodPost:
+		form, err := parseForm(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.secondaryNS = nil
+		for _, ns := range hostnamesSeparator.Split(form, -1) {
+			if ns = strings.TrimSpace(ns); ns != "" {
+				s.secondaryNS = append(s.secondaryNS, ns)
+			}
+		}
+		fmt.Fprint(w, "updated DNS: OK\n")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func parseForm(body []byte) (string, error) {
+	r, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	if err != nil {
+		return "", err
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := r.ParseForm(); err != nil {
+		return "", err
+	}
+	return r.PostForm.Get("hostnames"), nil
+}
+
+// addRecord adds a record unless an identical one exists, reporting whether it was added.
+func (s *Server) addRecord(qname string, rtype gomiabdns.RecordType, value string) bool {
+	for _, r := range s.records {
+		if r.QualifiedName == qname && r.RecordType == rtype && r.Value == value {
+			return false
+		}
+	}
+	record := gomiabdns.DNSRecord{QualifiedName: qname, RecordType: rtype, Value: value, Zone: s.zoneOf(qname)}
+	record.SortOrder.ByCreated = s.created
+	s.created++
+	s.records = append(s.records, record)
+	return true
+}
+
+// values returns the values of the records of qname and rtype.
+func (s *Server) values(qname string, rtype gomiabdns.RecordType) []string {
+	var values []string
+	for _, r := range s.records {
+		if r.QualifiedName == qname && r.RecordType == rtype {
+			values = append(values, r.Value)
+		}
+	}
+	return values
+}
+
+// deleteRecords deletes the records of qname and rtype, only the one with value if it isn't empty,
+// reporting whether any were deleted.
+func (s *Server) deleteRecords(qname string, rtype gomiabdns.RecordType, value string) bool {
+	kept := s.records[:0]
+	for _, r := range s.records {
+		if r.QualifiedName != qname || r.RecordType != rtype || (value != "" && r.Value != value) {
+			kept = append(kept, r)
+		}
+	}
+	deleted := len(kept) != len(s.records)
+	s.records = kept
+	return deleted
+}
+
+// listRecords returns a copy of the records with their sort orders filled in.
+func (s *Server) listRecords() []gomiabdns.DNSRecord {
+	result := slices.Clone(s.records)
+	byName := make([]int, len(result))
+	for i := range byName {
+		byName[i] = i
+	}
+	sort.SliceStable(byName, func(a, b int) bool {
+		return result[byName[a]].QualifiedName < result[byName[b]].QualifiedName
+	})
+	for order, i := range byName {
+		result[i].SortOrder.ByName = order
+	}
+	if result == nil {
+		result = []gomiabdns.DNSRecord{}
+	}
+	return result
+}
+
+// zoneOf returns the longest served zone qname is in, or "" if there is none.
+func (s *Server) zoneOf(qname string) string {
+	best := ""
+	for _, zone := range s.zones {
+		if (qname == zone || strings.HasSuffix(qname, "."+zone)) && len(zone) > len(best) {
+			best = zone
+		}
+	}
+	return best
+}
+
+func (s *Server) sortedZones() []string {
+	zones := slices.Clone(s.zones)
+	slices.Sort(zones)
+	if zones == nil {
+		zones = []string{}
+	}
+	return zones
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,248 @@
+package gomiabdns
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures a Client created by NewWithOptions.
+type Option func(c *Client) error
+
+// WithHTTPClient sets the http.Client used for all requests. See Client.HTTPClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) error {
+		c.HTTPClient = httpClient
+		return nil
+	}
+}
+
+// WithAdminUrl sets the url of the box's /admin api, for ex. https://proxy.example.com/some/prefix/admin,
+// for reverse proxies that don't mount it at the custom DNS endpoint's parent. See Client.AdminUrl.
+func WithAdminUrl(adminUrl string) Option {
+	return func(c *Client) error {
+		parsedUrl, err := parseApiUrl(adminUrl)
+		if err != nil {
+			return err
+		}
+		c.AdminUrl = parsedUrl
+		return nil
+	}
+}
+
+// WithTimeout bounds each request made to the API. See Client.Timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		c.Timeout = timeout
+		return nil
+	}
+}
+
+// WithMaxResponseSize sets the most bytes of a response body the client reads into memory. See
+// Client.MaxResponseSize.
+func WithMaxResponseSize(n int64) Option {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("Invalid max response size %d: must not be negative", n)
+		}
+		c.MaxResponseSize = n
+		return nil
+	}
+}
+
+// WithRetry retries requests that fail with a transient error up to maxAttempts times in
+// total, waiting baseDelay before the first retry. See RetryPolicy.
+func WithRetry(maxAttempts int, baseDelay time.Duration) Option {
+	return func(c *Client) error {
+		c.Retry.MaxAttempts = maxAttempts
+		c.Retry.BaseDelay = baseDelay
+		return nil
+	}
+}
+
+// WithTooManyRequestsRetry retries a request once after a 429 response, waiting as long as its
+// Retry-After header asks. See RetryPolicy.TooManyRequests.
+func WithTooManyRequestsRetry() Option {
+	return func(c *Client) error {
+		c.Retry.TooManyRequests = true
+		return nil
+	}
+}
+
+// WithSkipValidation turns off checking record values before they are sent. See Client.SkipValidation.
+func WithSkipValidation() Option {
+	return func(c *Client) error {
+		c.SkipValidation = true
+		return nil
+	}
+}
+
+// WithOrder makes GetHosts return records in order. See Client.Order.
+func WithOrder(order RecordOrder) Option {
+	return func(c *Client) error {
+		c.Order = order
+		return nil
+	}
+}
+
+// WithZoneCheck makes AddHost and UpdateHost refuse names outside the zones the box serves.
+// See Client.CheckZones.
+func WithZoneCheck() Option {
+	return func(c *Client) error {
+		c.CheckZones = true
+		return nil
+	}
+}
+
+// WithBatchConcurrency sets how many requests batch methods run at once. See Client.BatchConcurrency.
+func WithBatchConcurrency(n int) Option {
+	return func(c *Client) error {
+		c.BatchConcurrency = n
+		return nil
+	}
+}
+
+// WithDryRun logs mutations to the client's Logger instead of sending them. See Client.DryRun.
+func WithDryRun() Option {
+	return func(c *Client) error {
+		c.DryRun = true
+		return nil
+	}
+}
+
+// WithNoChangeError makes mutations that change nothing return ErrNoChange. See Client.NoChangeError.
+func WithNoChangeError() Option {
+	return func(c *Client) error {
+		c.NoChangeError = true
+		return nil
+	}
+}
+
+// WithClock makes the client take the current time from now instead of time.Now, when generating
+// TOTP codes and working out how long a Retry-After date asks to wait. It is meant for tests: the
+// waits themselves and the durations reported to Logger and Metrics still use the real clock.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) error {
+		if now == nil {
+			return fmt.Errorf("Invalid clock: must not be nil")
+		}
+		c.now = now
+		return nil
+	}
+}
+
+// WithLogger sends a debug record for every request to logger. See Client.Logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) error {
+		c.Logger = logger
+		return nil
+	}
+}
+
+// WithMetrics reports every request to metrics. See Client.Metrics.
+func WithMetrics(metrics Metrics) Option {
+	return func(c *Client) error {
+		c.Metrics = metrics
+		return nil
+	}
+}
+
+// WithRateLimit sends at most rps requests per second to the box, allowing bursts of burst
+// requests. See Client.RateLimit.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(c *Client) error {
+		c.RateLimit = rps
+		c.RateBurst = burst
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request. See Client.UserAgent.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) error {
+		c.UserAgent = userAgent
+		return nil
+	}
+}
+
+// WithTOTPSecret logs in with codes generated from the base32 encoded secret of the account's
+// two-factor authentication. See Client.TOTPSecret.
+func WithTOTPSecret(secret string) Option {
+	return func(c *Client) error {
+		if _, err := decodeTOTPSecret(secret); err != nil {
+			return err
+		}
+		c.TOTPSecret = secret
+		return nil
+	}
+}
+
+// WithTOTPURI is like WithTOTPSecret, but takes the otpauth://totp/ URI of the QR code shown when
+// two-factor authentication was set up.
+func WithTOTPURI(uri string) Option {
+	return func(c *Client) error {
+		secret, err := parseTOTPURI(uri)
+		if err != nil {
+			return err
+		}
+		c.TOTPSecret = secret
+		return nil
+	}
+}
+
+// WithTOTPCode logs in with a code the user read off their authenticator app. It takes precedence
+// over a TOTP secret. See Client.TOTPCode.
+func WithTOTPCode(code string) Option {
+	return func(c *Client) error {
+		if len(code) != totpDigits || strings.Trim(code, "0123456789") != "" {
+			return fmt.Errorf("Invalid TOTP code %q: must be %d digits", code, totpDigits)
+		}
+		c.TOTPCode = code
+		return nil
+	}
+}
+
+// WithHeaders adds headers to every request. See Client.Headers for which headers take precedence.
+func WithHeaders(headers http.Header) Option {
+	return func(c *Client) error {
+		c.Headers = headers.Clone()
+		return nil
+	}
+}
+
+// WithInsecureSkipVerify turns off verification of the box's TLS certificate. It is dangerous and
+// only meant for fresh boxes that still use a self-signed certificate. See Client.InsecureSkipVerify.
+func WithInsecureSkipVerify() Option {
+	return func(c *Client) error {
+		c.InsecureSkipVerify = true
+		return nil
+	}
+}
+
+// WithRootCA trusts only the PEM encoded certificate authorities in pemBytes for the box's
+// certificate, a safer alternative to WithInsecureSkipVerify for boxes using a private CA.
+// See Client.RootCAs.
+func WithRootCA(pemBytes []byte) Option {
+	return func(c *Client) error {
+		pool, err := parseRootCAs(pemBytes)
+		if err != nil {
+			return err
+		}
+		c.RootCAs = pool
+		return nil
+	}
+}
+
+// WithRootCAFile is WithRootCA reading the certificates from the file at path.
+func WithRootCAFile(path string) Option {
+	return func(c *Client) error {
+		pool, err := readRootCAs(path)
+		if err != nil {
+			return err
+		}
+		c.RootCAs = pool
+		return nil
+	}
+}
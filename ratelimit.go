@@ -0,0 +1,19 @@
+package gomiabdns
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// waitRateLimit delays a request until the client's rate limit allows it. It returns without
+// waiting if the context is done first or its deadline would pass before then.
+func (c *Client) waitRateLimit(ctx context.Context) error {
+	if c.RateLimit <= 0 {
+		return nil
+	}
+	c.rateLimiterOnce.Do(func() {
+		c.rateLimiter = rate.NewLimiter(rate.Limit(c.RateLimit), max(c.RateBurst, 1))
+	})
+	return c.rateLimiter.Wait(ctx)
+}
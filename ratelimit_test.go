@@ -0,0 +1,45 @@
+package gomiabdns_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luv2code/gomiabdns"
+)
+
+func TestRateLimit(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+	c, err := gomiabdns.NewWithOptions(srv.URL, "admin@example.com", "password", gomiabdns.WithRateLimit(20, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The burst of 2 goes at once, the other 4 requests wait 50ms each.
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if _, err := c.GetHosts(context.Background(), "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("6 requests took %s, want about 200ms", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetHosts(ctx, "", ""); err == nil {
+		t.Error("GetHosts succeeded, want an error for a context done before the limit allows the request")
+	}
+	if got := requests.Load(); got != 6 {
+		t.Errorf("the server got %d requests, want 6", got)
+	}
+}
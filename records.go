@@ -0,0 +1,391 @@
+package gomiabdns
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MXRecord is the value of an MX record.
+type MXRecord struct {
+	// Priority orders the mail servers of a name, lowest first.
+	Priority int
+	// Host is the name of the mail server.
+	Host string
+}
+
+// String formats the record the way the API expects it, "priority host".
+func (r MXRecord) String() string {
+	return fmt.Sprintf("%d %s", r.Priority, r.Host)
+}
+
+// Validate checks the priority is between 0 and 65535 and the host is a valid hostname, or "." for
+// the null MX of a name that accepts no mail (RFC 7505).
+func (r MXRecord) Validate() error {
+	if r.Priority < 0 || r.Priority > 65535 {
+		return fmt.Errorf("Invalid MX priority %d: must be from 0 to 65535", r.Priority)
+	}
+	if err := validateMXHost(r.Host); err != nil {
+		return fmt.Errorf("Invalid MX host %q: %w", r.Host, err)
+	}
+	return nil
+}
+
+// ParseMXRecord parses the value of an MX record as returned by the API.
+func ParseMXRecord(value string) (MXRecord, error) {
+	record, err := parseMX(value)
+	if err != nil {
+		return MXRecord{}, fmt.Errorf("Invalid MX value %q: %w", value, err)
+	}
+	return record, nil
+}
+
+func parseMX(value string) (MXRecord, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return MXRecord{}, fmt.Errorf("expected \"priority hostname\"")
+	}
+	priority, err := parseUint(fields[0], "priority", 65535)
+	if err != nil {
+		return MXRecord{}, err
+	}
+	if err := validateMXHost(fields[1]); err != nil {
+		return MXRecord{}, err
+	}
+	return MXRecord{Priority: priority, Host: fields[1]}, nil
+}
+
+// validateMXHost checks the host of an MX record, which is a hostname or the root "." of a null MX.
+func validateMXHost(host string) error {
+	if host == "." {
+		return nil
+	}
+	return validateHostname(host)
+}
+
+// AddMX adds an MX record for name pointing at host with the given priority.
+func (c *Client) AddMX(ctx context.Context, name string, priority int, host string) (MutationResult, error) {
+	record := MXRecord{Priority: priority, Host: host}
+	if err := record.Validate(); err != nil {
+		return MutationResult{}, err
+	}
+	return c.AddHost(ctx, name, MX, record.String())
+}
+
+// SRVRecord is the value of an SRV record.
+type SRVRecord struct {
+	// Priority orders the targets, lowest first.
+	Priority int
+	// Weight shares load between targets with the same priority.
+	Weight int
+	// Port is the port the service listens on.
+	Port int
+	// Target is the name of the host providing the service.
+	Target string
+}
+
+// String formats the record the way the API expects it, "priority weight port target".
+func (r SRVRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target)
+}
+
+// Validate checks priority, weight and port are between 0 and 65535 and target is a valid hostname.
+func (r SRVRecord) Validate() error {
+	for _, field := range []struct {
+		name  string
+		value int
+	}{{"priority", r.Priority}, {"weight", r.Weight}, {"port", r.Port}} {
+		if field.value < 0 || field.value > 65535 {
+			return fmt.Errorf("Invalid SRV %s %d: must be from 0 to 65535", field.name, field.value)
+		}
+	}
+	if err := validateHostname(r.Target); err != nil {
+		return fmt.Errorf("Invalid SRV target %q: %w", r.Target, err)
+	}
+	return nil
+}
+
+// ParseSRVRecord parses the value of an SRV record as returned by the API.
+func ParseSRVRecord(value string) (SRVRecord, error) {
+	record, err := parseSRV(value)
+	if err != nil {
+		return SRVRecord{}, fmt.Errorf("Invalid SRV value %q: %w", value, err)
+	}
+	return record, nil
+}
+
+func parseSRV(value string) (SRVRecord, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 4 {
+		return SRVRecord{}, fmt.Errorf("expected \"priority weight port target\"")
+	}
+	var numbers [3]int
+	for i, name := range []string{"priority", "weight", "port"} {
+		n, err := parseUint(fields[i], name, 65535)
+		if err != nil {
+			return SRVRecord{}, err
+		}
+		numbers[i] = n
+	}
+	if err := validateHostname(fields[3]); err != nil {
+		return SRVRecord{}, err
+	}
+	return SRVRecord{Priority: numbers[0], Weight: numbers[1], Port: numbers[2], Target: fields[3]}, nil
+}
+
+// AddSRV adds an SRV record for name, for ex. _sip._tcp.example.com.
+func (c *Client) AddSRV(ctx context.Context, name string, priority, weight, port int, target string) (MutationResult, error) {
+	record := SRVRecord{Priority: priority, Weight: weight, Port: port, Target: target}
+	if err := record.Validate(); err != nil {
+		return MutationResult{}, err
+	}
+	return c.AddHost(ctx, name, SRV, record.String())
+}
+
+// CAA record tags.
+const (
+	CAAIssue     = "issue"
+	CAAIssueWild = "issuewild"
+	CAAIodef     = "iodef"
+)
+
+// CAARecord is the value of a CAA record.
+type CAARecord struct {
+	// Flags is the flags byte. 128 marks the record as critical.
+	Flags int
+	// Tag is one of CAAIssue, CAAIssueWild or CAAIodef.
+	Tag string
+	// Value is the certificate authority's domain, or a URL for iodef, without quotes.
+	Value string
+}
+
+// String formats the record the way the API expects it, `flags tag "value"`.
+func (r CAARecord) String() string {
+	return fmt.Sprintf("%d %s %s", r.Flags, r.Tag, strconv.Quote(r.Value))
+}
+
+// Validate checks the flags fit in a byte and the tag is one of the known tags.
+func (r CAARecord) Validate() error {
+	if r.Flags < 0 || r.Flags > 255 {
+		return fmt.Errorf("Invalid CAA flags %d: must be from 0 to 255", r.Flags)
+	}
+	if err := validateCAATag(r.Tag); err != nil {
+		return fmt.Errorf("Invalid CAA record: %w", err)
+	}
+	return nil
+}
+
+func validateCAATag(tag string) error {
+	switch tag {
+	case CAAIssue, CAAIssueWild, CAAIodef:
+		return nil
+	}
+	return fmt.Errorf("tag %q must be one of %s, %s or %s", tag, CAAIssue, CAAIssueWild, CAAIodef)
+}
+
+// ParseCAARecord parses the value of a CAA record as returned by the API.
+func ParseCAARecord(value string) (CAARecord, error) {
+	record, err := parseCAA(value)
+	if err != nil {
+		return CAARecord{}, fmt.Errorf("Invalid CAA value %q: %w", value, err)
+	}
+	return record, nil
+}
+
+func parseCAA(value string) (CAARecord, error) {
+	fields := strings.SplitN(strings.TrimSpace(value), " ", 3)
+	if len(fields) != 3 || fields[2] == "" {
+		return CAARecord{}, fmt.Errorf("expected \"flags tag value\"")
+	}
+	flags, err := parseUint(fields[0], "flags", 255)
+	if err != nil {
+		return CAARecord{}, err
+	}
+	if err := validateCAATag(fields[1]); err != nil {
+		return CAARecord{}, err
+	}
+	caaValue := fields[2]
+	if strings.HasPrefix(caaValue, `"`) {
+		if caaValue, err = strconv.Unquote(caaValue); err != nil {
+			return CAARecord{}, fmt.Errorf("value %s is not properly quoted", fields[2])
+		}
+	}
+	return CAARecord{Flags: flags, Tag: fields[1], Value: caaValue}, nil
+}
+
+// AddCAA adds a CAA record for name. tag must be one of CAAIssue, CAAIssueWild or CAAIodef.
+func (c *Client) AddCAA(ctx context.Context, name string, flags int, tag, value string) (MutationResult, error) {
+	record := CAARecord{Flags: flags, Tag: tag, Value: value}
+	if err := record.Validate(); err != nil {
+		return MutationResult{}, err
+	}
+	return c.AddHost(ctx, name, CAA, record.String())
+}
+
+// SSHFPRecord is the value of an SSHFP record.
+type SSHFPRecord struct {
+	// Algorithm is the key algorithm: 1 RSA, 2 DSA, 3 ECDSA or 4 Ed25519.
+	Algorithm int
+	// Type is the fingerprint type: 1 SHA-1 or 2 SHA-256.
+	Type int
+	// Fingerprint is the hex encoded fingerprint of the key.
+	Fingerprint string
+}
+
+// sshfpFingerprintLen maps the fingerprint types to the length of their hex encoded digests.
+var sshfpFingerprintLen = map[int]int{1: 40, 2: 64}
+
+// String formats the record the way the API expects it, "algorithm type fingerprint".
+func (r SSHFPRecord) String() string {
+	return fmt.Sprintf("%d %d %s", r.Algorithm, r.Type, r.Fingerprint)
+}
+
+// Validate checks the algorithm and type are known and the fingerprint is hex of the right length.
+func (r SSHFPRecord) Validate() error {
+	if err := validateSSHFP(r); err != nil {
+		return fmt.Errorf("Invalid SSHFP record: %w", err)
+	}
+	return nil
+}
+
+func validateSSHFP(r SSHFPRecord) error {
+	if r.Algorithm < 1 || r.Algorithm > 4 {
+		return fmt.Errorf("algorithm %d must be from 1 to 4", r.Algorithm)
+	}
+	wantLen, ok := sshfpFingerprintLen[r.Type]
+	if !ok {
+		return fmt.Errorf("fingerprint type %d must be 1 or 2", r.Type)
+	}
+	if _, err := hex.DecodeString(r.Fingerprint); err != nil {
+		return fmt.Errorf("fingerprint %q is not hex", r.Fingerprint)
+	}
+	if len(r.Fingerprint) != wantLen {
+		return fmt.Errorf("fingerprint of type %d must be %d hex characters, got %d", r.Type, wantLen, len(r.Fingerprint))
+	}
+	return nil
+}
+
+// ParseSSHFPRecord parses the value of an SSHFP record as returned by the API.
+func ParseSSHFPRecord(value string) (SSHFPRecord, error) {
+	record, err := parseSSHFP(value)
+	if err != nil {
+		return SSHFPRecord{}, fmt.Errorf("Invalid SSHFP value %q: %w", value, err)
+	}
+	return record, nil
+}
+
+func parseSSHFP(value string) (SSHFPRecord, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 3 {
+		return SSHFPRecord{}, fmt.Errorf("expected \"algorithm type fingerprint\"")
+	}
+	algorithm, err := parseUint(fields[0], "algorithm", 255)
+	if err != nil {
+		return SSHFPRecord{}, err
+	}
+	fpType, err := parseUint(fields[1], "fingerprint type", 255)
+	if err != nil {
+		return SSHFPRecord{}, err
+	}
+	record := SSHFPRecord{Algorithm: algorithm, Type: fpType, Fingerprint: fields[2]}
+	if err := validateSSHFP(record); err != nil {
+		return SSHFPRecord{}, err
+	}
+	return record, nil
+}
+
+// AddSSHFP adds an SSHFP record for name.
+func (c *Client) AddSSHFP(ctx context.Context, name string, algorithm, fpType int, fingerprint string) (MutationResult, error) {
+	record := SSHFPRecord{Algorithm: algorithm, Type: fpType, Fingerprint: fingerprint}
+	if err := record.Validate(); err != nil {
+		return MutationResult{}, err
+	}
+	return c.AddHost(ctx, name, SSHFP, record.String())
+}
+
+// maxTXTSegment is the most bytes a single character-string of a TXT record can hold.
+const maxTXTSegment = 255
+
+// SplitTXT formats a TXT value as a zonefile does: split into quoted strings of at most 255 bytes,
+// with quotes and backslashes escaped, separated by spaces. Long values like DKIM keys don't fit
+// in one string. The box splits values this way itself when it writes its zonefile, so pass the
+// value to AddHost as it is, not split.
+func SplitTXT(value string) string {
+	var b strings.Builder
+	for {
+		segment := value
+		if len(segment) > maxTXTSegment {
+			segment = segment[:maxTXTSegment]
+		}
+		value = value[len(segment):]
+		b.WriteByte('"')
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(segment))
+		b.WriteByte('"')
+		if value == "" {
+			return b.String()
+		}
+		b.WriteByte(' ')
+	}
+}
+
+// JoinTXT reads back a TXT value split into quoted strings, as SplitTXT writes it and as it appears
+// in zonefiles and the output of DKIM key generators, by concatenating the strings. Parentheses
+// around them are ignored and \", \\ and \DDD escapes are decoded. A value that doesn't start
+// with a quote or parenthesis is returned unchanged.
+func JoinTXT(data string) (string, error) {
+	if trimmed := strings.TrimSpace(data); !strings.HasPrefix(trimmed, `"`) && !strings.HasPrefix(trimmed, "(") {
+		return data, nil
+	}
+	var b strings.Builder
+	inQuote := false
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+		switch {
+		case !inQuote && ch == '"':
+			inQuote = true
+		case !inQuote && strings.IndexByte(" \t\r\n()", ch) >= 0:
+		case !inQuote:
+			return "", fmt.Errorf("Invalid TXT data %q: text between quoted strings", data)
+		case ch == '"':
+			inQuote = false
+		case ch == '\\' && i+3 < len(data) && isDigits(data[i+1:i+4]):
+			n, _ := strconv.Atoi(data[i+1 : i+4])
+			if n > 255 {
+				return "", fmt.Errorf("Invalid TXT data %q: escape \\%s is not a byte", data, data[i+1:i+4])
+			}
+			b.WriteByte(byte(n))
+			i += 3
+		case ch == '\\' && i+1 < len(data):
+			i++
+			b.WriteByte(data[i])
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	if inQuote {
+		return "", fmt.Errorf("Invalid TXT data %q: unterminated quoted string", data)
+	}
+	return b.String(), nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// AddTXT adds a TXT record for name. A value split into quoted strings, for ex. a DKIM record copied
+// from a zonefile, is joined with JoinTXT first, since the box expects the value unsplit and would
+// keep the quotes as part of it. Values of any length are accepted, the box splits long ones.
+func (c *Client) AddTXT(ctx context.Context, name, value string) (MutationResult, error) {
+	joined, err := JoinTXT(value)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	return c.AddHost(ctx, name, TXT, joined)
+}
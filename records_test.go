@@ -0,0 +1,175 @@
+package gomiabdns_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luv2code/gomiabdns"
+)
+
+func TestParseMXRecord(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    gomiabdns.MXRecord
+		wantErr bool
+	}{
+		{value: "10 mail.example.com", want: gomiabdns.MXRecord{Priority: 10, Host: "mail.example.com"}},
+		{value: "0 mail.example.com.", want: gomiabdns.MXRecord{Priority: 0, Host: "mail.example.com."}},
+		// The null MX of RFC 7505, for a name that accepts no mail.
+		{value: "0 .", want: gomiabdns.MXRecord{Priority: 0, Host: "."}},
+		{value: "10", wantErr: true},
+		{value: "10 mail.example.com extra", wantErr: true},
+		{value: "65536 mail.example.com", wantErr: true},
+		{value: "10 ..", wantErr: true},
+		{value: "10 not_a host", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := gomiabdns.ParseMXRecord(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseMXRecord(%q) = %+v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMXRecord(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMXRecord(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+			if err := got.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+			if err := gomiabdns.ValidateValue(gomiabdns.MX, tt.value); err != nil {
+				t.Errorf("ValidateValue: %v", err)
+			}
+			if s := got.String(); s != tt.value {
+				t.Errorf("String() = %q, want the parsed value %q", s, tt.value)
+			}
+		})
+	}
+}
+
+func TestParseSRVRecord(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    gomiabdns.SRVRecord
+		wantErr bool
+	}{
+		{value: "10 60 5060 sip.example.com", want: gomiabdns.SRVRecord{Priority: 10, Weight: 60, Port: 5060, Target: "sip.example.com"}},
+		{value: "0 0 443 example.com.", want: gomiabdns.SRVRecord{Priority: 0, Weight: 0, Port: 443, Target: "example.com."}},
+		{value: "65535 65535 65535 a.example.com", want: gomiabdns.SRVRecord{Priority: 65535, Weight: 65535, Port: 65535, Target: "a.example.com"}},
+		{value: "10 60 5060", wantErr: true},
+		{value: "10 60 5060 sip.example.com extra", wantErr: true},
+		{value: "10 60 65536 sip.example.com", wantErr: true},
+		{value: "10 -1 5060 sip.example.com", wantErr: true},
+		{value: "ten 60 5060 sip.example.com", wantErr: true},
+		{value: "10 60 5060 not_a host", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := gomiabdns.ParseSRVRecord(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSRVRecord(%q) = %+v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSRVRecord(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSRVRecord(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+			if err := got.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+			if s := got.String(); s != tt.value {
+				t.Errorf("String() = %q, want the parsed value %q", s, tt.value)
+			}
+		})
+	}
+}
+
+func TestSplitTXT(t *testing.T) {
+	long := strings.Repeat("a", 255)
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: `""`},
+		{value: "v=spf1 mx -all", want: `"v=spf1 mx -all"`},
+		{value: `say "hi" \o/`, want: `"say \"hi\" \\o/"`},
+		{value: long, want: `"` + long + `"`},
+		{value: long + "bc", want: `"` + long + `" "bc"`},
+		{value: long + long + "c", want: `"` + long + `" "` + long + `" "c"`},
+		// The limit is in bytes, before escaping.
+		{value: strings.Repeat(`"`, 256), want: `"` + strings.Repeat(`\"`, 255) + `" "\""`},
+	}
+	for _, tt := range tests {
+		if got := gomiabdns.SplitTXT(tt.value); got != tt.want {
+			t.Errorf("SplitTXT(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestJoinTXT(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{name: "unquoted", data: "v=spf1 mx -all", want: "v=spf1 mx -all"},
+		{name: "unquoted with quotes inside", data: `v=DKIM1; p="abc"`, want: `v=DKIM1; p="abc"`},
+		{name: "single string", data: `"v=spf1 mx -all"`, want: "v=spf1 mx -all"},
+		{name: "empty string", data: `""`, want: ""},
+		{name: "several strings", data: `"v=DKIM1; k=rsa; " "p=MIIBIjAN" "BgkqhkiG9w0"`, want: "v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0"},
+		{name: "parentheses", data: "( \"v=DKIM1; \"\n\t\"p=abc\" )", want: "v=DKIM1; p=abc"},
+		{name: "surrounding space", data: `  "abc"  `, want: "abc"},
+		{name: "escaped quote and backslash", data: `"say \"hi\" \\o/"`, want: `say "hi" \o/`},
+		{name: "decimal escapes", data: `"a\059b\032c\255"`, want: "a;b c\xff"},
+		{name: "decimal escape at the end", data: `"\065"`, want: "A"},
+		{name: "short decimal escape", data: `"\06"`, want: "06"},
+		{name: "decimal escape over 255", data: `"\256"`, wantErr: true},
+		{name: "text between strings", data: `"abc" def "ghi"`, wantErr: true},
+		{name: "unterminated", data: `"abc`, wantErr: true},
+		{name: "escaped closing quote", data: `"abc\"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gomiabdns.JoinTXT(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("JoinTXT(%q) = %q, want an error", tt.data, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("JoinTXT(%q): %v", tt.data, err)
+			}
+			if got != tt.want {
+				t.Errorf("JoinTXT(%q) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitTXTRoundTrip(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"v=spf1 mx -all",
+		`quotes " and backslashes \ and \065`,
+		strings.Repeat("0123456789", 100),
+		strings.Repeat(`\"`, 300),
+		"multi-byte " + strings.Repeat("é", 200),
+	} {
+		got, err := gomiabdns.JoinTXT(gomiabdns.SplitTXT(value))
+		if err != nil {
+			t.Errorf("JoinTXT(SplitTXT(%q)): %v", value, err)
+		} else if got != value {
+			t.Errorf("JoinTXT(SplitTXT(%q)) = %q", value, got)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package gomiabdns
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how requests that fail with a transient error are retried. Timeouts, reset
+// and refused connections, responses cut short and 502, 503 and 504 responses are retried. POST
+// requests are never retried, since the box may already have acted on them.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made for a request. Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry. It doubles after every attempt, with jitter applied.
+	BaseDelay time.Duration
+	// TooManyRequests retries a request once after a 429 Too Many Requests response, as a rate
+	// limiting proxy in front of the box may send, waiting as long as its Retry-After header asks.
+	// Unlike other retries it applies to POST requests too, since the request wasn't acted on.
+	TooManyRequests bool
+}
+
+// defaultRetryAfter is the wait after a 429 response without a Retry-After header.
+const defaultRetryAfter = time.Second
+
+// tooManyRequests reports whether err is a 429 response the policy retries, and how long to wait first.
+func (p RetryPolicy) tooManyRequests(err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if !p.TooManyRequests || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, true
+	}
+	return defaultRetryAfter, true
+}
+
+// sleep waits for d, returning early with the context's error if it is done.
+func (c *Client) sleep(ctx context.Context, d time.Duration) error {
+	after := c.after
+	if after == nil {
+		after = time.After
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-after(d):
+		return nil
+	}
+}
+
+// backoff returns the wait before the retry that follows attempt, between half and all of
+// BaseDelay * 2^(attempt-1).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)) //nolint:gosec // jitter doesn't need a secure source
+}
+
+// permanentError stops withRetries from retrying the error it wraps.
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+func isRetryable(ctx context.Context, method string, err error) bool {
+	if method == http.MethodPost || ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	// Other failures to reach the box, like a TLS certificate it doesn't trust or a host that
+	// doesn't resolve, are mistakes in the configuration that retrying won't fix.
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,94 @@
+package gomiabdns
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordWaits makes c return from its waits at once, appending the durations to waits instead.
+func recordWaits(c *Client, waits *[]time.Duration) {
+	c.after = func(d time.Duration) <-chan time.Time {
+		*waits = append(*waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c, err := NewWithOptions(srv.URL, "admin@example.com", "password", WithRetry(4, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var waits []time.Duration
+	recordWaits(c, &waits)
+
+	if _, err := c.GetHosts(context.Background(), "", ""); err == nil {
+		t.Fatal("GetHosts succeeded against a box that is unavailable")
+	}
+	if got := attempts.Load(); got != 4 {
+		t.Errorf("got %d attempts, want 4", got)
+	}
+	if len(waits) != 3 {
+		t.Fatalf("waited %d times, want 3: %v", len(waits), waits)
+	}
+	for i, wait := range waits {
+		full := time.Hour << i
+		if wait < full/2 || wait > full {
+			t.Errorf("wait %d is %s, want between %s and %s", i+1, wait, full/2, full)
+		}
+	}
+}
+
+func TestTooManyRequestsWait(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		want       time.Duration
+	}{
+		{name: "seconds", retryAfter: "120", want: 2 * time.Minute},
+		{name: "date", retryAfter: "Fri, 16 Oct 2026 12:05:00 GMT", want: 5 * time.Minute},
+		{name: "missing", want: defaultRetryAfter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) == 1 {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					http.Error(w, "Too many requests", http.StatusTooManyRequests)
+					return
+				}
+				_, _ = w.Write([]byte("[]"))
+			}))
+			defer srv.Close()
+			now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+			c, err := NewWithOptions(srv.URL, "admin@example.com", "password", WithTooManyRequestsRetry(),
+				WithClock(func() time.Time { return now }))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var waits []time.Duration
+			recordWaits(c, &waits)
+
+			if _, err := c.GetHosts(context.Background(), "", ""); err != nil {
+				t.Fatal(err)
+			}
+			if len(waits) != 1 || waits[0] != tt.want {
+				t.Errorf("waited %v, want %s once", waits, tt.want)
+			}
+		})
+	}
+}
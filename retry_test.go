@@ -0,0 +1,121 @@
+package gomiabdns_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luv2code/gomiabdns"
+)
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		// tls serves the box with a certificate the client doesn't trust.
+		tls          bool
+		status       int
+		wantErr      bool
+		wantAttempts int32
+	}{
+		{name: "success", status: http.StatusOK, wantAttempts: 1},
+		{name: "service unavailable", status: http.StatusServiceUnavailable, wantErr: true, wantAttempts: 3},
+		{name: "bad gateway", status: http.StatusBadGateway, wantErr: true, wantAttempts: 3},
+		{name: "not found", status: http.StatusNotFound, wantErr: true, wantAttempts: 1},
+		{name: "untrusted certificate", tls: true, status: http.StatusOK, wantErr: true, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte("[]"))
+			})
+			var srv *httptest.Server
+			if tt.tls {
+				// The handshake fails on the client's side, after the server counted nothing.
+				srv = httptest.NewUnstartedServer(handler)
+				srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+					if state == http.StateNew {
+						attempts.Add(1)
+					}
+				}
+				srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+				srv.StartTLS()
+			} else {
+				srv = httptest.NewServer(handler)
+			}
+			defer srv.Close()
+			c, err := gomiabdns.NewWithOptions(srv.URL, "admin@example.com", "password", gomiabdns.WithRetry(3, time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = c.GetHosts(context.Background(), "", "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetHosts error = %v, want error: %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestTooManyRequestsRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		// failures is how many 429 responses the server sends before succeeding.
+		failures     int32
+		retryAfter   string
+		enabled      bool
+		wantErr      bool
+		wantAttempts int32
+		wantWait     time.Duration
+	}{
+		{name: "retried once", failures: 1, retryAfter: "1", enabled: true, wantAttempts: 2, wantWait: time.Second},
+		{name: "without Retry-After", failures: 1, enabled: true, wantAttempts: 2, wantWait: time.Second},
+		{name: "disabled", failures: 1, retryAfter: "1", wantErr: true, wantAttempts: 1},
+		{name: "only once", failures: 2, retryAfter: "0", enabled: true, wantErr: true, wantAttempts: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) <= tt.failures {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					http.Error(w, "Too many requests", http.StatusTooManyRequests)
+					return
+				}
+				_, _ = w.Write([]byte("[]"))
+			}))
+			defer srv.Close()
+			var opts []gomiabdns.Option
+			if tt.enabled {
+				opts = append(opts, gomiabdns.WithTooManyRequestsRetry())
+			}
+			c, err := gomiabdns.NewWithOptions(srv.URL, "admin@example.com", "password", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			_, err = c.GetHosts(context.Background(), "", "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetHosts error = %v, want error: %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", got, tt.wantAttempts)
+			}
+			if elapsed := time.Since(start); elapsed < tt.wantWait {
+				t.Errorf("GetHosts returned after %s, want a wait of %s first", elapsed, tt.wantWait)
+			}
+		})
+	}
+}
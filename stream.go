@@ -0,0 +1,62 @@
+package gomiabdns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ForEachHost calls fn for each record GetHosts would return, decoding them one at a time as the
+// response arrives instead of loading the whole list into memory. Use it for boxes with very many
+// records. If fn returns an error, iteration stops and that error is returned. The request is
+// only retried if it fails before any record has been passed to fn.
+func (c *Client) ForEachHost(ctx context.Context, name string, recordType RecordType, fn func(DNSRecord) error) error {
+	apiUrl := getApiWithPath(c.ApiUrl, name, recordType)
+	delivered := false
+	return c.withAuthentication(ctx, apiRequest{method: http.MethodGet, url: apiUrl.String()}, func(req apiRequest) error {
+		req.requestID = newRequestID()
+		return c.withRetries(ctx, req.method, func() error {
+			err := c.doAttempt(ctx, req, func(r io.Reader) error {
+				return decodeRecords(r, func(record DNSRecord) error {
+					delivered = true
+					return fn(record)
+				})
+			})
+			if err != nil && delivered {
+				return permanentError{err}
+			}
+			return err
+		})
+	})
+}
+
+// decodeRecords decodes a JSON array of records from r, calling fn for each as it is read.
+func decodeRecords(r io.Reader, fn func(DNSRecord) error) error {
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '['); err != nil {
+		return err
+	}
+	for decoder.More() {
+		var record DNSRecord
+		if err := decoder.Decode(&record); err != nil {
+			return fmt.Errorf("Error while decoding json: %w", err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return expectDelim(decoder, ']')
+}
+
+func expectDelim(decoder *json.Decoder, want json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("Error while decoding json: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("Error while decoding json: expected %q, got %v", want, token)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package gomiabdns
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// newInternalClient builds the http.Client used when the caller didn't provide one.
+func (c *Client) newInternalClient() *http.Client {
+	if !c.InsecureSkipVerify && c.RootCAs == nil {
+		return http.DefaultClient
+	}
+	defaultTransport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return http.DefaultClient
+	}
+	transport := defaultTransport.Clone()
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // explicitly requested through Client.InsecureSkipVerify
+		RootCAs:            c.RootCAs,
+	}
+	return &http.Client{Transport: transport}
+}
+
+// parseRootCAs builds a certificate pool from PEM encoded certificates.
+func parseRootCAs(pemBytes []byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, fmt.Errorf("Invalid root CA: no PEM encoded certificates found")
+	}
+	return pool, nil
+}
+
+func readRootCAs(path string) (*x509.CertPool, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading root CA file: %w", err)
+	}
+	return parseRootCAs(pemBytes)
+}
@@ -0,0 +1,127 @@
+package gomiabdns_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luv2code/gomiabdns"
+)
+
+// selfSignedServer is a box with a self-signed certificate, as a fresh box has.
+func selfSignedServer() *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+	}))
+	// The handshakes the client refuses are logged otherwise.
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	return srv
+}
+
+func TestInsecureSkipVerify(t *testing.T) {
+	srv := selfSignedServer()
+	defer srv.Close()
+
+	c, err := gomiabdns.New(srv.URL, "admin@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var certErr *tls.CertificateVerificationError
+	if _, err := c.GetHosts(context.Background(), "", ""); !errors.As(err, &certErr) {
+		t.Errorf("GetHosts error = %v by default, want a certificate verification error", err)
+	}
+
+	c, err = gomiabdns.NewWithOptions(srv.URL, "admin@example.com", "password", gomiabdns.WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetHosts(context.Background(), "", ""); err != nil {
+		t.Errorf("GetHosts with WithInsecureSkipVerify: %v", err)
+	}
+}
+
+// otherCA returns a PEM encoded CA certificate that didn't sign the certificate of httptest servers.
+func otherCA(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Other CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestRootCA(t *testing.T) {
+	srv := selfSignedServer()
+	defer srv.Close()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	otherPEM := otherCA(t)
+
+	tests := []struct {
+		name       string
+		opt        gomiabdns.Option
+		wantOptErr bool
+		wantErr    bool
+	}{
+		{name: "server's certificate", opt: gomiabdns.WithRootCA(caPEM)},
+		{name: "server's certificate from file", opt: gomiabdns.WithRootCAFile(caFile)},
+		{name: "another certificate", opt: gomiabdns.WithRootCA(otherPEM), wantErr: true},
+		{name: "bad PEM", opt: gomiabdns.WithRootCA([]byte("-----BEGIN CERTIFICATE-----\nnot base64\n-----END CERTIFICATE-----\n")), wantOptErr: true},
+		{name: "no PEM", opt: gomiabdns.WithRootCA(srv.Certificate().Raw), wantOptErr: true},
+		{name: "missing file", opt: gomiabdns.WithRootCAFile(filepath.Join(t.TempDir(), "missing.pem")), wantOptErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := gomiabdns.NewWithOptions(srv.URL, "admin@example.com", "password", tt.opt)
+			if tt.wantOptErr {
+				if err == nil || !strings.Contains(err.Error(), "root CA") {
+					t.Errorf("NewWithOptions error = %v, want an error about the root CA", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = c.GetHosts(context.Background(), "", "")
+			var certErr *tls.CertificateVerificationError
+			if tt.wantErr && !errors.As(err, &certErr) {
+				t.Errorf("GetHosts error = %v, want a certificate verification error", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("GetHosts: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package gomiabdns
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // TOTP as the box implements it uses HMAC-SHA1
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The TOTP parameters the box uses for two-factor authentication. They are the RFC 6238 defaults
+// authenticator apps assume.
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+)
+
+// generateTOTP returns the RFC 6238 code for the base32 encoded secret at t.
+func generateTOTP(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(totpPeriod/time.Second)))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, code%1000000), nil
+}
+
+// decodeTOTPSecret decodes a base32 secret, ignoring case, spaces and padding as authenticator
+// apps do.
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Join(strings.Fields(secret), ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("Invalid TOTP secret: must be base32 encoded")
+	}
+	return key, nil
+}
+
+// parseTOTPURI returns the secret of an otpauth://totp/ URI, as encoded in the QR code shown when
+// two-factor authentication is set up. Its algorithm, digits and period must be the ones the box uses.
+func parseTOTPURI(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("Invalid TOTP URI: %w", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		return "", fmt.Errorf("Invalid TOTP URI: must start with otpauth://totp/")
+	}
+	query := u.Query()
+	secret := query.Get("secret")
+	if secret == "" {
+		return "", fmt.Errorf("Invalid TOTP URI: missing secret")
+	}
+	if _, err := decodeTOTPSecret(secret); err != nil {
+		return "", err
+	}
+	if algorithm := query.Get("algorithm"); algorithm != "" && !strings.EqualFold(algorithm, "SHA1") {
+		return "", fmt.Errorf("Invalid TOTP URI: algorithm %s is not supported, the box uses SHA1", algorithm)
+	}
+	if digits := query.Get("digits"); digits != "" && digits != fmt.Sprint(totpDigits) {
+		return "", fmt.Errorf("Invalid TOTP URI: %s digits are not supported, the box uses %d", digits, totpDigits)
+	}
+	if period := query.Get("period"); period != "" && period != fmt.Sprint(int(totpPeriod/time.Second)) {
+		return "", fmt.Errorf("Invalid TOTP URI: a period of %ss is not supported, the box uses %s", period, totpPeriod)
+	}
+	return secret, nil
+}
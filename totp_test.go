@@ -0,0 +1,112 @@
+package gomiabdns_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luv2code/gomiabdns"
+	"github.com/luv2code/gomiabdns/miabtest"
+	"golang.org/x/exp/slices"
+)
+
+// rfc6238Secret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// totpServer is a box whose login accepts only the codes in accept, and records the codes it was sent.
+type totpServer struct {
+	mu     sync.Mutex
+	accept []string
+	tokens []string
+}
+
+func (s *totpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := r.Header.Get("x-auth-token")
+	s.tokens = append(s.tokens, token)
+	if s.accept != nil && !slices.Contains(s.accept, token) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "invalid", "reason": "invalid-totp-token"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": "ok", "email": "admin@example.com", "privileges": []string{"admin"}, "api_key": "key"})
+}
+
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	// The SHA1 vectors of RFC 6238 appendix B, truncated to the 6 digits the box uses.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	fake := miabtest.NewServer()
+	defer fake.Close()
+	fake.SetTOTPSecret(rfc6238Secret)
+	for _, tt := range tests {
+		// miabtest has its own copy of the algorithm, which must agree with the RFC too.
+		if got := fake.TOTPCode(time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("miabtest code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+		box := &totpServer{}
+		srv := httptest.NewServer(box)
+		c, err := gomiabdns.NewWithOptions(srv.URL, "admin@example.com", "password", gomiabdns.WithTOTPSecret(rfc6238Secret),
+			gomiabdns.WithClock(func() time.Time { return time.Unix(tt.unix, 0) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Login(context.Background()); err != nil {
+			t.Errorf("Login at %d: %v", tt.unix, err)
+		}
+		if !slices.Equal(box.tokens, []string{tt.want}) {
+			t.Errorf("Login at %d sent the codes %q, want %s", tt.unix, box.tokens, tt.want)
+		}
+		srv.Close()
+	}
+}
+
+func TestTOTPClockSkew(t *testing.T) {
+	tests := []struct {
+		name string
+		// now is the client's clock. 1111111109 and 1111111111 are in adjacent windows.
+		now     int64
+		accept  []string
+		wantErr error
+		// wantTokens are the codes sent in order. "" is one that isn't an RFC 6238 vector.
+		wantTokens []string
+	}{
+		{name: "current window", now: 1111111111, accept: []string{"050471"}, wantTokens: []string{"050471"}},
+		{name: "clock ahead", now: 1111111111, accept: []string{"081804"}, wantTokens: []string{"050471", "081804"}},
+		{name: "clock behind", now: 1111111109, accept: []string{"050471"}, wantTokens: []string{"081804", "", "050471"}},
+		{name: "clock too far off", now: 1234567890, accept: []string{"050471"}, wantErr: gomiabdns.ErrTOTPRejected, wantTokens: []string{"005924", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := &totpServer{accept: tt.accept}
+			srv := httptest.NewServer(box)
+			defer srv.Close()
+			c, err := gomiabdns.NewWithOptions(srv.URL, "admin@example.com", "password", gomiabdns.WithTOTPSecret(rfc6238Secret),
+				gomiabdns.WithClock(func() time.Time { return time.Unix(tt.now, 0) }))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Login(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Login error = %v, want %v", err, tt.wantErr)
+			}
+			matches := func(token, want string) bool { return want == "" || token == want }
+			if !slices.EqualFunc(box.tokens, tt.wantTokens, matches) {
+				t.Errorf("Login sent the codes %q, want %q", box.tokens, tt.wantTokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package gomiabdns
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ValidateValue checks that value is well formed for recordType. Record types the client doesn't
+// know how to check are accepted as is.
+func ValidateValue(recordType RecordType, value string) error {
+	var err error
+	switch recordType {
+	case A:
+		err = validateIPv4(value)
+	case AAAA:
+		err = validateIPv6(value)
+	case CNAME, NS:
+		err = validateHostname(value)
+	case MX:
+		_, err = parseMX(value)
+	case SRV:
+		_, err = parseSRV(value)
+	case CAA:
+		_, err = parseCAA(value)
+	case SSHFP:
+		_, err = parseSSHFP(value)
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid %s value %q: %w", recordType, value, err)
+	}
+	return nil
+}
+
+func validateIPv4(value string) error {
+	ip := net.ParseIP(value)
+	if ip == nil || ip.To4() == nil || strings.Contains(value, ":") {
+		return fmt.Errorf("not an IPv4 address")
+	}
+	return nil
+}
+
+func validateIPv6(value string) error {
+	ip := net.ParseIP(value)
+	if ip == nil || !strings.Contains(value, ":") {
+		return fmt.Errorf("not an IPv6 address")
+	}
+	return nil
+}
+
+// validateHostname checks value is a domain name made of letters, digits, hyphens and underscores.
+// A trailing dot is allowed.
+func validateHostname(value string) error {
+	name := strings.TrimSuffix(value, ".")
+	if name == "" {
+		return fmt.Errorf("hostname is empty")
+	}
+	if len(name) > 253 {
+		return fmt.Errorf("hostname is longer than 253 characters")
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("hostname label %q must be between 1 and 63 characters", label)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("hostname label %q can't start or end with a hyphen", label)
+		}
+		for _, r := range label {
+			if !isHostnameChar(r) {
+				return fmt.Errorf("hostname label %q contains invalid character %q", label, r)
+			}
+		}
+	}
+	return nil
+}
+
+func isHostnameChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_'
+}
+
+// parseUint parses a decimal field of a record value and checks it is no greater than limit.
+func parseUint(field, name string, limit uint64) (int, error) {
+	n, err := strconv.ParseUint(field, 10, 32)
+	if err != nil || n > limit {
+		return 0, fmt.Errorf("%s %q must be a number from 0 to %d", name, field, limit)
+	}
+	return int(n), nil
+}
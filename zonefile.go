@@ -0,0 +1,374 @@
+package gomiabdns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ZoneFileRecord is a resource record from a zonefile. Unlike DNSRecord, it can be any record the
+// box serves, including the ones it manages itself like SOA, NS and the A records of the box.
+type ZoneFileRecord struct {
+	// Name is the fully qualified owner name, without a trailing dot.
+	Name string
+	// TTL is the time to live in seconds.
+	TTL int
+	// Class is the record class, almost always IN.
+	Class string
+	// Type is the record type, for ex. SOA.
+	Type RecordType
+	// Data is the rdata as written in the zonefile, with comments removed and the lines of a
+	// multi-line record joined. Names in it are not qualified.
+	Data string
+}
+
+// String formats the record as a zonefile line.
+func (r ZoneFileRecord) String() string {
+	return fmt.Sprintf("%s. %d %s %s %s", r.Name, r.TTL, r.Class, r.Type, r.Data)
+}
+
+// GetZonefile returns the zonefile the box serves for zone, in BIND master file format.
+func (c *Client) GetZonefile(ctx context.Context, zone string) (string, error) {
+	if zone == "" {
+		return "", fmt.Errorf("Missing parameter to GetZonefile. zone is required.")
+	}
+	apiResp, err := c.doRequest(ctx, http.MethodGet, c.adminUrl("dns", "zonefile", zone).String(), "")
+	if err != nil {
+		return "", err
+	}
+	return string(apiResp), nil
+}
+
+// GetZonefileRecords returns the records of the zonefile the box serves for zone.
+func (c *Client) GetZonefileRecords(ctx context.Context, zone string) ([]ZoneFileRecord, error) {
+	zonefile, err := c.GetZonefile(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	records, err := ParseZonefile(zonefile, zone)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid zonefile for %s: %w", zone, err)
+	}
+	return records, nil
+}
+
+// ZoneInfo is the SOA record of a zone. Its times are in seconds.
+type ZoneInfo struct {
+	Zone string
+	// Serial changes whenever the zone does, so comparing it tells whether a zone needs backing up again.
+	Serial uint32
+	// PrimaryNS is the primary nameserver of the zone.
+	PrimaryNS string
+	// AdminEmail is the address of the zone's administrator.
+	AdminEmail string
+	Refresh    int
+	Retry      int
+	Expire     int
+	Minimum    int
+}
+
+// GetZoneInfo returns the SOA record of the zonefile the box serves for zone.
+func (c *Client) GetZoneInfo(ctx context.Context, zone string) (ZoneInfo, error) {
+	records, err := c.GetZonefileRecords(ctx, zone)
+	if err != nil {
+		return ZoneInfo{}, err
+	}
+	for _, record := range records {
+		if record.Type == "SOA" {
+			info, err := parseSOA(record.Data, strings.TrimSuffix(zone, "."))
+			if err != nil {
+				return ZoneInfo{}, fmt.Errorf("Invalid SOA record for %s: %w", zone, err)
+			}
+			info.Zone = record.Name
+			return info, nil
+		}
+	}
+	return ZoneInfo{}, fmt.Errorf("Invalid zonefile for %s: no SOA record", zone)
+}
+
+// parseSOA parses the rdata of an SOA record, "mname rname serial refresh retry expire minimum".
+func parseSOA(data, origin string) (ZoneInfo, error) {
+	fields := strings.Fields(data)
+	if len(fields) != 7 {
+		return ZoneInfo{}, fmt.Errorf("expected 7 fields, got %d", len(fields))
+	}
+	serial, err := strconv.ParseUint(fields[2], 10, 32)
+	if err != nil {
+		return ZoneInfo{}, fmt.Errorf("invalid serial %q", fields[2])
+	}
+	info := ZoneInfo{
+		Serial:     uint32(serial),
+		PrimaryNS:  qualifyName(fields[0], origin),
+		AdminEmail: mailboxToEmail(qualifyName(fields[1], origin)),
+	}
+	for i, field := range []*int{&info.Refresh, &info.Retry, &info.Expire, &info.Minimum} {
+		if *field, err = parseTTL(fields[3+i]); err != nil {
+			return ZoneInfo{}, err
+		}
+	}
+	return info, nil
+}
+
+// mailboxToEmail turns the mailbox of an SOA record into an email address: its first label, in
+// which dots are escaped, is the local part. hostmaster.example.com becomes hostmaster@example.com.
+func mailboxToEmail(mailbox string) string {
+	for i := 0; i < len(mailbox); i++ {
+		switch mailbox[i] {
+		case '\\':
+			i++
+		case '.':
+			return strings.ReplaceAll(mailbox[:i], `\.`, ".") + "@" + mailbox[i+1:]
+		}
+	}
+	return mailbox
+}
+
+// ParseZonefile parses a zonefile in the RFC 1035 master file format. Relative names are
+// qualified with origin until a $ORIGIN directive changes it. $TTL, multi-line records in
+// parentheses, comments and omitted owners, TTLs and classes are handled. $INCLUDE is not supported.
+func ParseZonefile(zonefile, origin string) ([]ZoneFileRecord, error) {
+	lines, err := splitZonefile(zonefile)
+	if err != nil {
+		return nil, err
+	}
+	origin = strings.TrimSuffix(origin, ".")
+	var records []ZoneFileRecord
+	defaultTTL := -1
+	var last ZoneFileRecord
+	for _, line := range lines {
+		if strings.HasPrefix(line.tokens[0], "$") {
+			if err := parseDirective(line, &origin, &defaultTTL); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		record, err := parseZoneRecord(line, origin, defaultTTL, last)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+		records = append(records, record)
+		last = record
+	}
+	return records, nil
+}
+
+// zoneLine is a logical line of a zonefile: one entry, which parentheses may spread over
+// several physical lines.
+type zoneLine struct {
+	// number is the physical line the entry starts on.
+	number int
+	// blankOwner is set when the entry starts with whitespace, so it has the previous owner.
+	blankOwner bool
+	tokens     []string
+}
+
+// splitZonefile splits a zonefile into logical lines of tokens. Quoted strings are kept as one
+// token, quotes included.
+func splitZonefile(zonefile string) ([]zoneLine, error) {
+	var lines []zoneLine
+	var line zoneLine
+	var token strings.Builder
+	inToken, inQuote, startOfLine := false, false, true
+	depth, number := 0, 1
+	endToken := func() {
+		if inToken {
+			line.tokens = append(line.tokens, token.String())
+			token.Reset()
+			inToken = false
+		}
+	}
+	for i := 0; i < len(zonefile); i++ {
+		ch := zonefile[i]
+		if inQuote {
+			token.WriteByte(ch)
+			switch ch {
+			case '\\':
+				if i+1 < len(zonefile) {
+					i++
+					token.WriteByte(zonefile[i])
+				}
+			case '"':
+				inQuote = false
+			case '\n':
+				number++
+			}
+			continue
+		}
+		switch ch {
+		case '\n':
+			number++
+			endToken()
+			if depth == 0 {
+				if len(line.tokens) > 0 {
+					lines = append(lines, line)
+				}
+				line = zoneLine{}
+				startOfLine = true
+				continue
+			}
+		case ';':
+			for i+1 < len(zonefile) && zonefile[i+1] != '\n' {
+				i++
+			}
+		case ' ', '\t', '\r':
+			if startOfLine && depth == 0 {
+				line.blankOwner = true
+			}
+			endToken()
+		case '(':
+			endToken()
+			depth++
+		case ')':
+			if depth == 0 {
+				return nil, fmt.Errorf("line %d: unbalanced parenthesis", number)
+			}
+			endToken()
+			depth--
+		default:
+			if !inToken {
+				if len(line.tokens) == 0 {
+					line.number = number
+				}
+				inToken = true
+			}
+			token.WriteByte(ch)
+			if ch == '"' {
+				inQuote = true
+			} else if ch == '\\' && i+1 < len(zonefile) {
+				i++
+				token.WriteByte(zonefile[i])
+			}
+		}
+		startOfLine = false
+	}
+	if inQuote {
+		return nil, fmt.Errorf("line %d: unterminated quoted string", number)
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("line %d: unbalanced parenthesis", number)
+	}
+	endToken()
+	if len(line.tokens) > 0 {
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+func parseDirective(line zoneLine, origin *string, defaultTTL *int) error {
+	directive := strings.ToUpper(line.tokens[0])
+	switch directive {
+	case "$ORIGIN", "$TTL":
+	case "$INCLUDE":
+		return fmt.Errorf("line %d: $INCLUDE is not supported", line.number)
+	default:
+		return fmt.Errorf("line %d: unknown directive %s", line.number, line.tokens[0])
+	}
+	if len(line.tokens) != 2 {
+		return fmt.Errorf("line %d: %s takes one argument", line.number, directive)
+	}
+	if directive == "$ORIGIN" {
+		*origin = qualifyName(line.tokens[1], *origin)
+		return nil
+	}
+	ttl, err := parseTTL(line.tokens[1])
+	if err != nil {
+		return fmt.Errorf("line %d: %w", line.number, err)
+	}
+	*defaultTTL = ttl
+	return nil
+}
+
+// parseZoneRecord parses a resource record line. Omitted fields are taken from the $TTL
+// directive and the previous record, as RFC 1035 specifies.
+func parseZoneRecord(line zoneLine, origin string, defaultTTL int, last ZoneFileRecord) (ZoneFileRecord, error) {
+	tokens := line.tokens
+	record := ZoneFileRecord{TTL: -1}
+	if line.blankOwner {
+		if last.Name == "" {
+			return ZoneFileRecord{}, fmt.Errorf("no owner name and no previous record")
+		}
+		record.Name = last.Name
+	} else {
+		record.Name = qualifyName(tokens[0], origin)
+		tokens = tokens[1:]
+	}
+	for len(tokens) > 0 {
+		if isZoneClass(tokens[0]) && record.Class == "" {
+			record.Class = strings.ToUpper(tokens[0])
+		} else if ttl, err := parseTTL(tokens[0]); err == nil && record.TTL < 0 {
+			record.TTL = ttl
+		} else {
+			break
+		}
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return ZoneFileRecord{}, fmt.Errorf("missing record type")
+	}
+	record.Type = RecordType(strings.ToUpper(tokens[0]))
+	record.Data = strings.Join(tokens[1:], " ")
+	if record.Class == "" {
+		record.Class = last.Class
+		if record.Class == "" {
+			record.Class = "IN"
+		}
+	}
+	if record.TTL < 0 {
+		record.TTL = defaultTTL
+		if record.TTL < 0 {
+			if last.Name == "" {
+				return ZoneFileRecord{}, fmt.Errorf("no TTL, $TTL directive or previous record")
+			}
+			record.TTL = last.TTL
+		}
+	}
+	return record, nil
+}
+
+func isZoneClass(token string) bool {
+	switch strings.ToUpper(token) {
+	case "IN", "CH", "HS", "CS":
+		return true
+	}
+	return false
+}
+
+// parseTTL parses a TTL in seconds, or in the BIND notation with units, for ex. 1h30m.
+func parseTTL(token string) (int, error) {
+	if ttl, err := strconv.ParseUint(token, 10, 31); err == nil {
+		return int(ttl), nil
+	}
+	units := map[byte]int{'w': 604800, 'd': 86400, 'h': 3600, 'm': 60, 's': 1}
+	total, start := 0, 0
+	for i := 0; i < len(token); i++ {
+		unit, ok := units[token[i]|0x20]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(token[start:i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid TTL %q", token)
+		}
+		total += n * unit
+		start = i + 1
+	}
+	if start == 0 || start != len(token) {
+		return 0, fmt.Errorf("invalid TTL %q", token)
+	}
+	return total, nil
+}
+
+// qualifyName makes name fully qualified relative to origin and drops the trailing dot.
+func qualifyName(name, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return strings.TrimSuffix(name, ".")
+	case origin == "":
+		return name
+	}
+	return name + "." + origin
+}
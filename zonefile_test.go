@@ -0,0 +1,97 @@
+package gomiabdns_test
+
+import (
+	"testing"
+
+	"github.com/luv2code/gomiabdns"
+	"golang.org/x/exp/slices"
+)
+
+func TestParseZonefile(t *testing.T) {
+	tests := []struct {
+		name     string
+		zonefile string
+		want     []gomiabdns.ZoneFileRecord
+		wantErr  bool
+	}{
+		{
+			name: "as the box writes it",
+			zonefile: `$ORIGIN example.com.
+$TTL 86400          ; default time to live
+
+@ IN SOA box.example.com. hostmaster.example.com. (
+           2026101601 ; serial number
+           7200       ; Refresh (secondary nameserver update interval)
+           3600       ; Retry (when refresh fails, how often to try again, should be lower than the refresh)
+           1209600    ; Expire (when refresh fails, how long secondary nameserver will keep records around anyway)
+           86400      ; Negative TTL (how long negative responses are cached)
+           )
+	IN	NS	ns1.box.example.com.
+	IN	NS	ns2.box.example.com.
+	IN	MX	10 box.example.com.
+mail._domainkey	IN	TXT	( "v=DKIM1; k=rsa; s=email; "
+	"p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA" )
+www	1800	IN	A	1.2.3.4
+`,
+			want: []gomiabdns.ZoneFileRecord{
+				{Name: "example.com", TTL: 86400, Class: "IN", Type: "SOA", Data: "box.example.com. hostmaster.example.com. 2026101601 7200 3600 1209600 86400"},
+				{Name: "example.com", TTL: 86400, Class: "IN", Type: gomiabdns.NS, Data: "ns1.box.example.com."},
+				{Name: "example.com", TTL: 86400, Class: "IN", Type: gomiabdns.NS, Data: "ns2.box.example.com."},
+				{Name: "example.com", TTL: 86400, Class: "IN", Type: gomiabdns.MX, Data: "10 box.example.com."},
+				{Name: "mail._domainkey.example.com", TTL: 86400, Class: "IN", Type: gomiabdns.TXT, Data: `"v=DKIM1; k=rsa; s=email; " "p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA"`},
+				{Name: "www.example.com", TTL: 1800, Class: "IN", Type: gomiabdns.A, Data: "1.2.3.4"},
+			},
+		},
+		{
+			name: "origin argument and changes",
+			zonefile: `www 300 A 1.2.3.4
+$ORIGIN sub
+@ 1h cname www.example.com.
+mail.example.org. 2w1d IN A 1.2.3.5
+`,
+			want: []gomiabdns.ZoneFileRecord{
+				{Name: "www.example.com", TTL: 300, Class: "IN", Type: gomiabdns.A, Data: "1.2.3.4"},
+				{Name: "sub.example.com", TTL: 3600, Class: "IN", Type: gomiabdns.CNAME, Data: "www.example.com."},
+				{Name: "mail.example.org", TTL: 1296000, Class: "IN", Type: gomiabdns.A, Data: "1.2.3.5"},
+			},
+		},
+		{
+			name: "fields taken from the previous record",
+			zonefile: `www IN 600 A 1.2.3.4
+     AAAA ::1
+txt TXT "semicolon; in (quotes)"`,
+			want: []gomiabdns.ZoneFileRecord{
+				{Name: "www.example.com", TTL: 600, Class: "IN", Type: gomiabdns.A, Data: "1.2.3.4"},
+				{Name: "www.example.com", TTL: 600, Class: "IN", Type: gomiabdns.AAAA, Data: "::1"},
+				{Name: "txt.example.com", TTL: 600, Class: "IN", Type: gomiabdns.TXT, Data: `"semicolon; in (quotes)"`},
+			},
+		},
+		{name: "empty", zonefile: "; nothing here\n\n"},
+		{name: "no owner", zonefile: "  IN A 1.2.3.4", wantErr: true},
+		{name: "no ttl", zonefile: "www IN A 1.2.3.4", wantErr: true},
+		{name: "no type", zonefile: "$TTL 300\nwww IN", wantErr: true},
+		{name: "bad ttl", zonefile: "$TTL 5x", wantErr: true},
+		{name: "include", zonefile: "$INCLUDE other.zone", wantErr: true},
+		{name: "unknown directive", zonefile: "$GENERATE 1-10 host$ A 1.2.3.$", wantErr: true},
+		{name: "unclosed parenthesis", zonefile: "$TTL 300\n@ SOA a. b. ( 1 2 3 4 5", wantErr: true},
+		{name: "unopened parenthesis", zonefile: "$TTL 300\n@ A 1.2.3.4 )", wantErr: true},
+		{name: "unterminated quote", zonefile: "$TTL 300\n@ TXT \"abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gomiabdns.ParseZonefile(tt.zonefile, "example.com.")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseZonefile returned %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseZonefile returned\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
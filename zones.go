@@ -0,0 +1,143 @@
+package gomiabdns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GetZones returns the DNS zones the box serves, for ex. the domains of its mail users.
+func (c *Client) GetZones(ctx context.Context) ([]string, error) {
+	apiResp, err := c.doRequest(ctx, http.MethodGet, c.adminUrl("dns", "zones").String(), "")
+	if err != nil {
+		return nil, err
+	}
+	var zones []string
+	if err := unmarshalJSON(apiResp, &zones); err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// GetAllRecordsByZone returns the custom records of every zone the box serves, keyed by zone.
+// Zones without custom records map to an empty slice. The API can't list the records of a single
+// zone, so all records are fetched once and grouped here.
+func (c *Client) GetAllRecordsByZone(ctx context.Context) (map[string][]DNSRecord, error) {
+	zones, err := c.GetZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	records, err := c.GetAllRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]DNSRecord, len(zones))
+	for _, zone := range zones {
+		result[zone] = []DNSRecord{}
+	}
+	for _, record := range records {
+		result[record.Zone] = append(result[record.Zone], record)
+	}
+	return result, nil
+}
+
+// GetZonesWithCounts returns the number of custom records in every zone the box serves, keyed by
+// zone. Zones without custom records map to 0.
+func (c *Client) GetZonesWithCounts(ctx context.Context) (map[string]int, error) {
+	recordsByZone, err := c.GetAllRecordsByZone(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(recordsByZone))
+	for zone, records := range recordsByZone {
+		counts[zone] = len(records)
+	}
+	return counts, nil
+}
+
+// ResetZoneCache forgets the zones cached for CheckZones, so they are fetched again before the next
+// mutation. Call it after adding a domain to the box.
+func (c *Client) ResetZoneCache() {
+	c.zonesMu.Lock()
+	defer c.zonesMu.Unlock()
+	c.zones = nil
+}
+
+// checkZone returns an error if CheckZones is set and name isn't in a zone the box serves.
+func (c *Client) checkZone(ctx context.Context, name string) error {
+	if !c.CheckZones {
+		return nil
+	}
+	zones, err := c.cachedZones(ctx)
+	if err != nil {
+		return err
+	}
+	name = NormalizeName(name)
+	for _, zone := range zones {
+		zone = NormalizeName(zone)
+		if name == zone || strings.HasSuffix(name, "."+zone) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid name %q: not in any zone the box serves", name)
+}
+
+// cachedZones returns the zones the box serves, fetching them the first time.
+func (c *Client) cachedZones(ctx context.Context) ([]string, error) {
+	c.zonesMu.Lock()
+	defer c.zonesMu.Unlock()
+	if c.zones == nil {
+		zones, err := c.GetZones(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.zones = append([]string{}, zones...)
+	}
+	return c.zones, nil
+}
+
+// DumpRecord is a record of the DNS the box generates for a zone, as listed on its External DNS
+// page.
+type DumpRecord struct {
+	QualifiedName string     `json:"qname"`
+	RecordType    RecordType `json:"rtype"`
+	Value         string     `json:"value"`
+	// Explanation is the box's description of what the record is for. It starts with
+	// "Required." or "Recommended." for the records that must or should be set at an external
+	// DNS provider when the box isn't the zone's nameserver.
+	Explanation string `json:"explanation"`
+}
+
+// DumpDNS returns the records the box generates for every zone it serves, keyed by zone, from its
+// /admin/dns/dump endpoint. Unlike GetAllRecords, these include the records the box manages
+// itself, like its A, MX, SPF, DKIM and DMARC records, along with the custom ones. Within a zone,
+// required records come first, then recommended ones, then the rest.
+//
+// The endpoint returns a list of [zone, records] pairs:
+//
+//	[["example.com", [{"qname": "example.com", "rtype": "MX", "value": "10 box.example.com.", "explanation": "Required. ..."}, ...]], ...]
+func (c *Client) DumpDNS(ctx context.Context) (map[string][]DumpRecord, error) {
+	apiResp, err := c.doRequest(ctx, http.MethodGet, c.adminUrl("dns", "dump").String(), "")
+	if err != nil {
+		return nil, err
+	}
+	var pairs [][2]json.RawMessage
+	if err := unmarshalJSON(apiResp, &pairs); err != nil {
+		return nil, err
+	}
+	result := make(map[string][]DumpRecord, len(pairs))
+	for _, pair := range pairs {
+		var zone string
+		var records []DumpRecord
+		if err := unmarshalJSON(pair[0], &zone); err != nil {
+			return nil, err
+		}
+		if err := unmarshalJSON(pair[1], &records); err != nil {
+			return nil, err
+		}
+		result[zone] = records
+	}
+	return result, nil
+}